@@ -0,0 +1,153 @@
+package httpsling
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// BulkheadConfig defines settings for the Bulkhead middleware
+type BulkheadConfig struct {
+	// MaxConcurrent is the maximum number of requests in flight for each key (default 10)
+	MaxConcurrent int
+	// MaxQueue is the maximum number of requests waiting for a slot for each key; requests arriving when the queue
+	// is full are rejected with a *BulkheadError. 0 rejects requests as soon as all slots are taken
+	MaxQueue int
+	// Key returns the key of the bulkhead a request belongs to, e.g. HostKey; nil puts all requests in a single bulkhead
+	Key KeyFunc
+}
+
+func (c *BulkheadConfig) normalize() {
+	if c.MaxConcurrent < 1 {
+		c.MaxConcurrent = 10
+	}
+
+	if c.MaxQueue < 0 {
+		c.MaxQueue = 0
+	}
+
+	if c.Key == nil {
+		c.Key = func(*http.Request) string { return "" }
+	}
+}
+
+// BulkheadError is returned by the Bulkhead middleware when a request is rejected because the queue is full
+type BulkheadError struct {
+	// Key is the key of the bulkhead the request belongs to
+	Key string
+	// MaxConcurrent is the maximum number of requests in flight
+	MaxConcurrent int
+	// MaxQueue is the maximum number of waiting requests
+	MaxQueue int
+}
+
+// Error implements error
+func (e *BulkheadError) Error() string {
+	return fmt.Sprintf("%s: %q has %d requests in flight and %d waiting", ErrBulkheadFull, e.Key, e.MaxConcurrent, e.MaxQueue)
+}
+
+// Unwrap returns ErrBulkheadFull
+func (e *BulkheadError) Unwrap() error {
+	return ErrBulkheadFull
+}
+
+// Bulkhead caps the number of requests in flight for each key, so a slow upstream can't tie up every goroutine
+// of the client. A request holds its slot until the response body is closed, or until it fails. Requests over the
+// limit wait in a bounded queue, respecting their context; installed before Retry, the bulkhead counts a request
+// and all its retries as one
+func Bulkhead(config *BulkheadConfig) Middleware {
+	c := BulkheadConfig{}
+	if config != nil {
+		c = *config
+	}
+
+	c.normalize()
+
+	var (
+		mu        sync.Mutex
+		bulkheads = map[string]*bulkhead{}
+	)
+
+	get := func(key string) *bulkhead {
+		mu.Lock()
+		defer mu.Unlock()
+
+		b, ok := bulkheads[key]
+		if !ok {
+			b = &bulkhead{slots: make(chan struct{}, c.MaxConcurrent)}
+			bulkheads[key] = b
+		}
+
+		return b
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			key := c.Key(req)
+			b := get(key)
+
+			if err := b.acquire(req, c.MaxQueue); err != nil {
+				if errors.Is(err, ErrBulkheadFull) {
+					return nil, &BulkheadError{Key: key, MaxConcurrent: c.MaxConcurrent, MaxQueue: c.MaxQueue}
+				}
+
+				return nil, err
+			}
+
+			resp, err := next.Do(req)
+			if err != nil || resp == nil || resp.Body == nil {
+				b.release()
+
+				return resp, err
+			}
+
+			resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: b.release}
+
+			return resp, nil
+		})
+	}
+}
+
+// bulkhead holds the slots of a single key
+type bulkhead struct {
+	slots chan struct{}
+
+	mu     sync.Mutex
+	queued int
+}
+
+// acquire takes a slot, waiting in the queue if none is free; it returns ErrBulkheadFull if the queue is full
+func (b *bulkhead) acquire(req *http.Request, maxQueue int) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	b.mu.Lock()
+	if b.queued >= maxQueue {
+		b.mu.Unlock()
+		return ErrBulkheadFull
+	}
+
+	b.queued++
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		b.queued--
+		b.mu.Unlock()
+	}()
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+func (b *bulkhead) release() {
+	<-b.slots
+}
@@ -0,0 +1,129 @@
+package httpsling_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+func TestBulkhead(t *testing.T) {
+	started := make(chan struct{}, 2)
+	unblock := make(chan struct{})
+
+	s := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		started <- struct{}{}
+		<-unblock
+	}))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Bulkhead(&httpsling.BulkheadConfig{MaxConcurrent: 1, MaxQueue: 1}))
+
+	results := make(chan error, 2)
+
+	for range 2 {
+		go func() {
+			resp, err := r.Send()
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			results <- err
+		}()
+	}
+
+	// one request is in flight and the other is waiting, so the queue is full
+	<-started
+	time.Sleep(20 * time.Millisecond)
+
+	_, err := r.Send() // nolint: bodyclose
+	require.ErrorIs(t, err, httpsling.ErrBulkheadFull)
+
+	var bulkheadErr *httpsling.BulkheadError
+	require.ErrorAs(t, err, &bulkheadErr)
+	assert.Equal(t, 1, bulkheadErr.MaxConcurrent)
+
+	close(unblock)
+
+	require.NoError(t, <-results)
+	require.NoError(t, <-results)
+	assert.Len(t, started, 1)
+}
+
+func TestBulkheadBodyHoldsSlot(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(http.StatusOK, httpsling.Body("ok")))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Bulkhead(&httpsling.BulkheadConfig{MaxConcurrent: 1}))
+
+	resp, err := r.Send()
+	require.NoError(t, err)
+
+	// the slot is held until the body is closed
+	_, err = r.Send() // nolint: bodyclose
+	require.ErrorIs(t, err, httpsling.ErrBulkheadFull)
+
+	require.NoError(t, resp.Body.Close())
+
+	resp, err = r.Send()
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+}
+
+func TestBulkheadContext(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(http.StatusOK))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Bulkhead(&httpsling.BulkheadConfig{MaxConcurrent: 1, MaxQueue: 1, Key: httpsling.HostKey}))
+
+	resp, err := r.Send()
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = r.SendWithContext(ctx) // nolint: bodyclose
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// other hosts have their own bulkhead
+	other := httptest.NewServer(httpsling.MockHandler(http.StatusOK))
+	defer other.Close()
+
+	resp, err = r.Send(httpsling.Get(other.URL))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+}
+
+func TestBulkheadWithRetry(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(http.StatusServiceUnavailable))
+	defer s.Close()
+
+	i := httptestutil.Inspect(s)
+
+	// the bulkhead wraps retries, which drain and close the body of each failed attempt
+	r := httptestutil.Requester(s,
+		httpsling.Bulkhead(&httpsling.BulkheadConfig{MaxConcurrent: 1}),
+		httpsling.Retry(&httpsling.RetryConfig{MaxAttempts: 3, Backoff: httpsling.NoBackoff()}),
+	)
+
+	resp, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Len(t, i.Drain(), 3)
+
+	resp, err = r.Receive(nil)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+}
@@ -0,0 +1,480 @@
+package httpsling
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Values of the X-Cache header set by the Cache middleware
+const (
+	// CacheHit marks a fresh response served from the cache without contacting the upstream
+	CacheHit = "HIT"
+	// CacheMiss marks a response fetched from the upstream
+	CacheMiss = "MISS"
+	// CacheRevalidated marks a stored response the upstream confirmed to still be valid with a 304 Not Modified
+	CacheRevalidated = "REVALIDATED"
+	// CacheStale marks a stale response served because of stale-while-revalidate or stale-if-error
+	CacheStale = "STALE"
+)
+
+// DefaultMaxCacheBodySize is the default size above which response bodies are not cached
+const DefaultMaxCacheBodySize = 10 << 20
+
+// CacheConfig defines settings for the Cache middleware
+type CacheConfig struct {
+	// Store persists the cached responses (default a MemoryCache holding DefaultCacheEntries responses)
+	Store CacheStore
+	// Key returns the key a response is stored under (default the request URL); it is also used to invalidate the
+	// stored response when an unsafe request is sent, so it should not depend on the request method
+	Key KeyFunc
+	// MaxBodySize is the size above which response bodies are not cached (default DefaultMaxCacheBodySize)
+	MaxBodySize int64
+}
+
+func (c *CacheConfig) normalize() {
+	if c.Store == nil {
+		c.Store = NewMemoryCache(DefaultCacheEntries)
+	}
+
+	if c.Key == nil {
+		c.Key = func(req *http.Request) string { return req.URL.String() }
+	}
+
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = DefaultMaxCacheBodySize
+	}
+}
+
+// Cache is a private HTTP cache following RFC 9111: GET responses are stored according to their Cache-Control,
+// Expires and Last-Modified headers, served while fresh, and revalidated with If-None-Match and If-Modified-Since
+// once stale. Responses are matched on the request headers named by Vary, stale responses are served as allowed by
+// the stale-while-revalidate and stale-if-error directives (RFC 5861), and successful unsafe requests invalidate
+// the stored response of their URL. The X-Cache response header tells whether a response came from the cache.
+// Install it before Retry, so revalidation requests are retried rather than each attempt being cached
+func Cache(config *CacheConfig) Middleware {
+	c := CacheConfig{}
+	if config != nil {
+		c = *config
+	}
+
+	c.normalize()
+
+	h := &httpCache{config: c, revalidating: map[string]bool{}}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return h.do(next, req)
+		})
+	}
+}
+
+// httpCache holds the state of a Cache middleware
+type httpCache struct {
+	config CacheConfig
+
+	mu           sync.Mutex
+	revalidating map[string]bool
+}
+
+func (h *httpCache) do(next Doer, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	if req.Method != http.MethodGet {
+		resp, err := next.Do(req)
+		if err == nil && !safeMethod(req.Method) && resp.StatusCode < http.StatusBadRequest {
+			_ = h.config.Store.Delete(ctx, h.config.Key(req))
+		}
+
+		return resp, err
+	}
+
+	reqCC := parseCacheControl(req.Header)
+
+	// requests which are already conditional, or ask for part of the resource, are left to the caller
+	if reqCC.has("no-store") || conditionalRequest(req) {
+		return next.Do(req)
+	}
+
+	key := h.config.Key(req)
+
+	entry, err := h.config.Store.Get(ctx, key)
+	if err != nil || !entry.matches(req) {
+		return h.fetch(next, req, key)
+	}
+
+	respCC := parseCacheControl(entry.Header)
+	age := entry.age(time.Now())
+	lifetime := entry.freshnessLifetime()
+	revalidate := reqCC.has("no-cache") || respCC.has("no-cache")
+
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		revalidate = true
+	}
+
+	if !revalidate && age < lifetime {
+		return entry.response(req, CacheHit, age), nil
+	}
+
+	if swr, ok := respCC.seconds("stale-while-revalidate"); ok && !revalidate && !respCC.has("must-revalidate") && age-lifetime < swr {
+		h.revalidateInBackground(next, req, key, entry)
+
+		return entry.response(req, CacheStale, age), nil
+	}
+
+	return h.revalidate(next, req, key, entry)
+}
+
+// fetch sends the request and stores the response if it can be cached
+func (h *httpCache) fetch(next Doer, req *http.Request, key string) (*http.Response, error) {
+	requestTime := time.Now()
+
+	resp, err := next.Do(req)
+	if err != nil {
+		return resp, err
+	}
+
+	return h.store(req, key, resp, requestTime), nil
+}
+
+// revalidate sends a conditional request for the stored entry
+func (h *httpCache) revalidate(next Doer, req *http.Request, key string, entry *CacheEntry) (*http.Response, error) {
+	condReq := req.Clone(req.Context())
+
+	if etag := entry.Header.Get(HeaderETag); etag != "" {
+		condReq.Header.Set(HeaderIfNoneMatch, etag)
+	}
+
+	if lastModified := entry.Header.Get(HeaderLastModified); lastModified != "" {
+		condReq.Header.Set(HeaderIfModifiedSince, lastModified)
+	}
+
+	requestTime := time.Now()
+
+	resp, err := next.Do(condReq)
+
+	if entry.staleIfError(req, resp, err) {
+		if resp != nil {
+			drain(resp.Body)
+		}
+
+		return entry.response(req, CacheStale, entry.age(time.Now())), nil
+	}
+
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode != http.StatusNotModified {
+		return h.store(req, key, resp, requestTime), nil
+	}
+
+	drain(resp.Body)
+
+	updated := entry.refresh(resp, requestTime)
+	_ = h.config.Store.Set(req.Context(), key, updated)
+
+	return updated.response(req, CacheRevalidated, updated.age(time.Now())), nil
+}
+
+// revalidateInBackground revalidates the entry without holding up the request; only one revalidation runs per key
+func (h *httpCache) revalidateInBackground(next Doer, req *http.Request, key string, entry *CacheEntry) {
+	h.mu.Lock()
+	if h.revalidating[key] {
+		h.mu.Unlock()
+		return
+	}
+
+	h.revalidating[key] = true
+	h.mu.Unlock()
+
+	bgReq := req.Clone(context.WithoutCancel(req.Context()))
+
+	go func() {
+		defer func() {
+			h.mu.Lock()
+			delete(h.revalidating, key)
+			h.mu.Unlock()
+		}()
+
+		if resp, err := h.revalidate(next, bgReq, key, entry); err == nil {
+			drain(resp.Body)
+		}
+	}()
+}
+
+// store reads the body of the response and stores it if the response can be cached; the response is returned
+// with its body intact either way
+func (h *httpCache) store(req *http.Request, key string, resp *http.Response, requestTime time.Time) *http.Response {
+	resp.Header.Set(HeaderXCache, CacheMiss)
+
+	if !storable(resp) {
+		return resp
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, h.config.MaxBodySize+1))
+	if err != nil || int64(len(body)) > h.config.MaxBodySize {
+		resp.Body = &prefixedReadCloser{
+			Reader: io.MultiReader(bytes.NewReader(body), &errReader{err: err}, resp.Body),
+			Closer: resp.Body,
+		}
+
+		return resp
+	}
+
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry := &CacheEntry{
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		RequestTime:  requestTime,
+		ResponseTime: time.Now(),
+	}
+
+	entry.Header.Del(HeaderXCache)
+
+	for _, name := range headerTokens(resp.Header, HeaderVary) {
+		if entry.VaryHeader == nil {
+			entry.VaryHeader = http.Header{}
+		}
+
+		entry.VaryHeader[http.CanonicalHeaderKey(name)] = req.Header.Values(name)
+	}
+
+	_ = h.config.Store.Set(req.Context(), key, entry)
+
+	return resp
+}
+
+// cacheableByDefault are the status codes which can be cached with a heuristic freshness lifetime
+var cacheableByDefault = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// storable returns true if the response may be stored, and is useful to store: it must be fresh for some time or
+// carry a validator
+func storable(resp *http.Response) bool {
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-store") {
+		return false
+	}
+
+	for _, name := range headerTokens(resp.Header, HeaderVary) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	_, explicit := cc.seconds("max-age")
+	explicit = explicit || resp.Header.Get(HeaderExpires) != ""
+
+	if !explicit && !cacheableByDefault[resp.StatusCode] {
+		return false
+	}
+
+	return explicit || resp.Header.Get(HeaderETag) != "" || resp.Header.Get(HeaderLastModified) != ""
+}
+
+// matches returns true if the request has the same values for the headers named by Vary as the request
+// the entry was stored for
+func (e *CacheEntry) matches(req *http.Request) bool {
+	for name, values := range e.VaryHeader {
+		if strings.Join(req.Header.Values(name), ", ") != strings.Join(values, ", ") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// date returns the Date header of the entry, or the time it was received
+func (e *CacheEntry) date() time.Time {
+	if date, err := http.ParseTime(e.Header.Get(HeaderDate)); err == nil {
+		return date
+	}
+
+	return e.ResponseTime
+}
+
+// age returns the current age of the entry (RFC 9111 section 4.2.3)
+func (e *CacheEntry) age(now time.Time) time.Duration {
+	apparentAge := max(0, e.ResponseTime.Sub(e.date()))
+	ageValue, _ := parseDeltaSeconds(e.Header.Get(HeaderAge))
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+
+	return max(apparentAge, correctedAge) + now.Sub(e.ResponseTime)
+}
+
+// freshnessLifetime returns how long the entry is fresh for (RFC 9111 section 4.2.1); without explicit freshness
+// information, 10% of the time since the resource was last modified is used
+func (e *CacheEntry) freshnessLifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+
+	if maxAge, ok := cc.seconds("max-age"); ok {
+		return maxAge
+	}
+
+	if v := e.Header.Get(HeaderExpires); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+
+		return expires.Sub(e.date())
+	}
+
+	if !cacheableByDefault[e.StatusCode] {
+		return 0
+	}
+
+	if lastModified, err := http.ParseTime(e.Header.Get(HeaderLastModified)); err == nil {
+		return max(0, e.date().Sub(lastModified)/10) // nolint: mnd
+	}
+
+	return 0
+}
+
+// staleIfError returns true if the entry may be served in place of the failed revalidation
+func (e *CacheEntry) staleIfError(req *http.Request, resp *http.Response, err error) bool {
+	if err == nil {
+		switch resp.StatusCode {
+		case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		default:
+			return false
+		}
+	}
+
+	respCC := parseCacheControl(e.Header)
+	if respCC.has("must-revalidate") || respCC.has("no-cache") {
+		return false
+	}
+
+	window, ok := respCC.seconds("stale-if-error")
+	if reqWindow, reqOK := parseCacheControl(req.Header).seconds("stale-if-error"); reqOK {
+		window, ok = reqWindow, true
+	}
+
+	return ok && e.age(time.Now())-e.freshnessLifetime() < window
+}
+
+// refresh returns a copy of the entry updated with the headers of a 304 Not Modified response
+func (e *CacheEntry) refresh(resp *http.Response, requestTime time.Time) *CacheEntry {
+	updated := *e
+	updated.Header = e.Header.Clone()
+	updated.RequestTime = requestTime
+	updated.ResponseTime = time.Now()
+
+	for name, values := range resp.Header {
+		if name == HeaderContentLength || name == HeaderXCache {
+			continue
+		}
+
+		updated.Header[name] = values
+	}
+
+	return &updated
+}
+
+// response builds a response for the request from the entry
+func (e *CacheEntry) response(req *http.Request, status string, age time.Duration) *http.Response {
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+
+	resp.Header.Set(HeaderAge, strconv.FormatInt(int64(age.Seconds()), 10))
+	resp.Header.Set(HeaderXCache, status)
+
+	return resp
+}
+
+// safeMethod returns true for the methods defined as safe by RFC 9110
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// conditionalRequest returns true if the request carries its own preconditions or range
+func conditionalRequest(req *http.Request) bool {
+	for _, name := range []string{HeaderIfNoneMatch, HeaderIfModifiedSince, HeaderIfMatch, HeaderIfUnmodifiedSince, HeaderIfRange, HeaderRange} {
+		if req.Header.Get(name) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// headerTokens returns the comma separated tokens of all the values of the header
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+
+	for _, v := range h.Values(name) {
+		for _, token := range strings.Split(v, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+
+	return tokens
+}
+
+// cacheControl holds the directives of the Cache-Control header, keyed by their lower case name
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+
+	for _, directive := range headerTokens(h, HeaderCacheControl) {
+		name, value, _ := strings.Cut(directive, "=")
+		cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+
+	return ok
+}
+
+// seconds returns the value of a directive holding delta-seconds
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	v, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+
+	return parseDeltaSeconds(v)
+}
@@ -0,0 +1,296 @@
+package httpsling_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+// cachedGet sends a GET request and returns the X-Cache header and the body
+func cachedGet(t *testing.T, r *httpsling.Requester, opts ...httpsling.Option) (string, string) {
+	t.Helper()
+
+	resp, err := r.Send(opts...)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp.Header.Get(httpsling.HeaderXCache), string(body)
+}
+
+func TestCache(t *testing.T) {
+	var calls atomic.Int32
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set(httpsling.HeaderCacheControl, "max-age=60")
+		w.Write([]byte("cached")) // nolint: errcheck
+	}))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Cache(nil))
+
+	status, body := cachedGet(t, r)
+	assert.Equal(t, httpsling.CacheMiss, status)
+	assert.Equal(t, "cached", body)
+
+	status, body = cachedGet(t, r)
+	assert.Equal(t, httpsling.CacheHit, status)
+	assert.Equal(t, "cached", body)
+	assert.EqualValues(t, 1, calls.Load())
+
+	// no-cache in the request forces a revalidation, which without validators fetches the resource again
+	status, _ = cachedGet(t, r, httpsling.Header(httpsling.HeaderCacheControl, "no-cache"))
+	assert.Equal(t, httpsling.CacheMiss, status)
+	assert.EqualValues(t, 2, calls.Load())
+
+	// a successful unsafe request invalidates the stored response
+	resp, err := r.Send(httpsling.Post())
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	status, _ = cachedGet(t, r)
+	assert.Equal(t, httpsling.CacheMiss, status)
+}
+
+func TestCacheRevalidation(t *testing.T) {
+	lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	var (
+		calls       atomic.Int32
+		notModified atomic.Int32
+	)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		w.Header().Set(httpsling.HeaderCacheControl, "no-cache")
+		w.Header().Set(httpsling.HeaderETag, `"v1"`)
+		w.Header().Set(httpsling.HeaderLastModified, lastModified)
+
+		if r.Header.Get(httpsling.HeaderIfNoneMatch) == `"v1"` && r.Header.Get(httpsling.HeaderIfModifiedSince) == lastModified {
+			notModified.Add(1)
+			w.Header().Set("X-Revalidated", "yes")
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		w.Write([]byte("body")) // nolint: errcheck
+	}))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Cache(nil))
+
+	status, _ := cachedGet(t, r)
+	assert.Equal(t, httpsling.CacheMiss, status)
+
+	var out string
+
+	resp, err := r.Receive(&out)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Equal(t, httpsling.CacheRevalidated, resp.Header.Get(httpsling.HeaderXCache))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "body", out)
+	assert.Equal(t, "yes", resp.Header.Get("X-Revalidated"))
+	assert.EqualValues(t, 2, calls.Load())
+	assert.EqualValues(t, 1, notModified.Load())
+}
+
+func TestCacheVary(t *testing.T) {
+	var calls atomic.Int32
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set(httpsling.HeaderCacheControl, "max-age=60")
+		w.Header().Set(httpsling.HeaderVary, httpsling.HeaderAccept)
+		w.Write([]byte(r.Header.Get(httpsling.HeaderAccept))) // nolint: errcheck
+	}))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Cache(nil))
+	json := httpsling.Accept(httpsling.ContentTypeJSON)
+	text := httpsling.Accept(httpsling.ContentTypeText)
+
+	status, _ := cachedGet(t, r, json)
+	assert.Equal(t, httpsling.CacheMiss, status)
+
+	status, body := cachedGet(t, r, json)
+	assert.Equal(t, httpsling.CacheHit, status)
+	assert.Equal(t, httpsling.ContentTypeJSON, body)
+
+	status, body = cachedGet(t, r, text)
+	assert.Equal(t, httpsling.CacheMiss, status)
+	assert.Equal(t, httpsling.ContentTypeText, body)
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+func TestCacheNotStored(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.Handler
+		opts    []httpsling.Option
+	}{
+		{
+			name:    "no-store",
+			handler: httpsling.MockHandler(http.StatusOK, httpsling.Header(httpsling.HeaderCacheControl, "no-store, max-age=60")),
+		},
+		{
+			name:    "no freshness or validators",
+			handler: httpsling.MockHandler(http.StatusOK),
+		},
+		{
+			name:    "vary all",
+			handler: httpsling.MockHandler(http.StatusOK, httpsling.Header(httpsling.HeaderCacheControl, "max-age=60"), httpsling.Header(httpsling.HeaderVary, "*")),
+		},
+		{
+			name:    "status not cacheable by default",
+			handler: httpsling.MockHandler(http.StatusCreated, httpsling.Header(httpsling.HeaderETag, `"v1"`)),
+		},
+		{
+			name:    "request no-store",
+			handler: httpsling.MockHandler(http.StatusOK, httpsling.Header(httpsling.HeaderCacheControl, "max-age=60")),
+			opts:    []httpsling.Option{httpsling.Header(httpsling.HeaderCacheControl, "no-store")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := httptest.NewServer(test.handler)
+			defer s.Close()
+
+			i := httptestutil.Inspect(s)
+			r := httptestutil.Requester(s, httpsling.Cache(nil))
+
+			for range 2 {
+				status, _ := cachedGet(t, r, test.opts...)
+				assert.NotEqual(t, httpsling.CacheHit, status)
+			}
+
+			assert.Len(t, i.Drain(), 2)
+		})
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	var calls atomic.Int32
+
+	revalidated := make(chan struct{}, 1)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := calls.Add(1)
+
+		w.Header().Set(httpsling.HeaderCacheControl, "max-age=0, stale-while-revalidate=60")
+		w.Write([]byte{'0' + byte(n)}) // nolint: errcheck
+
+		if n > 1 {
+			revalidated <- struct{}{}
+		}
+	}))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Cache(nil))
+
+	status, body := cachedGet(t, r)
+	assert.Equal(t, httpsling.CacheMiss, status)
+	assert.Equal(t, "1", body)
+
+	// the stale response is served while it is revalidated in the background
+	status, body = cachedGet(t, r)
+	assert.Equal(t, httpsling.CacheStale, status)
+	assert.Equal(t, "1", body)
+
+	<-revalidated
+
+	require.Eventually(t, func() bool {
+		_, body = cachedGet(t, r)
+		return body == "2"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+	var fail atomic.Bool
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set(httpsling.HeaderCacheControl, "max-age=0, stale-if-error=60")
+		w.Write([]byte("ok")) // nolint: errcheck
+	}))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Cache(nil))
+
+	cachedGet(t, r)
+	fail.Store(true)
+
+	status, body := cachedGet(t, r)
+	assert.Equal(t, httpsling.CacheStale, status)
+	assert.Equal(t, "ok", body)
+
+	// the request can shrink the window
+	resp, err := r.Send(httpsling.Header(httpsling.HeaderCacheControl, "stale-if-error=0"))
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestCacheDiskStore(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(http.StatusOK,
+		httpsling.Header(httpsling.HeaderCacheControl, "max-age=60"),
+		httpsling.Body("persisted"),
+	))
+	defer s.Close()
+
+	dir := t.TempDir()
+
+	store, err := httpsling.NewDiskCache(dir)
+	require.NoError(t, err)
+
+	cachedGet(t, httptestutil.Requester(s, httpsling.Cache(&httpsling.CacheConfig{Store: store})))
+
+	// a new cache using the same directory serves the stored response
+	store, err = httpsling.NewDiskCache(dir)
+	require.NoError(t, err)
+
+	status, body := cachedGet(t, httptestutil.Requester(s, httpsling.Cache(&httpsling.CacheConfig{Store: store})))
+	assert.Equal(t, httpsling.CacheHit, status)
+	assert.Equal(t, "persisted", body)
+}
+
+func TestCacheMaxBodySize(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(http.StatusOK,
+		httpsling.Header(httpsling.HeaderCacheControl, "max-age=60"),
+		httpsling.Body("too large"),
+	))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Cache(&httpsling.CacheConfig{MaxBodySize: 4}))
+
+	for range 2 {
+		status, body := cachedGet(t, r)
+		assert.Equal(t, httpsling.CacheMiss, status)
+		assert.Equal(t, "too large", body)
+	}
+}
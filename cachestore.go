@@ -0,0 +1,226 @@
+package httpsling
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultCacheEntries is the number of responses held by the MemoryCache used when no CacheStore is configured
+const DefaultCacheEntries = 1000
+
+// CacheEntry is a response stored by the Cache middleware
+type CacheEntry struct {
+	// StatusCode is the status code of the response
+	StatusCode int `json:"statusCode"`
+	// Header contains the response headers
+	Header http.Header `json:"header"`
+	// Body contains the response body
+	Body []byte `json:"body"`
+	// VaryHeader contains the values of the request headers named by the Vary response header
+	VaryHeader http.Header `json:"varyHeader,omitempty"`
+	// RequestTime is the time the request which fetched the response was sent
+	RequestTime time.Time `json:"requestTime"`
+	// ResponseTime is the time the response was received
+	ResponseTime time.Time `json:"responseTime"`
+}
+
+// CacheStore persists the responses of the Cache middleware
+type CacheStore interface {
+	// Get returns the entry stored under key, or ErrCacheMiss
+	Get(ctx context.Context, key string) (*CacheEntry, error)
+	// Set stores the entry under key, replacing any existing entry
+	Set(ctx context.Context, key string, entry *CacheEntry) error
+	// Delete removes the entry stored under key; deleting a missing entry is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryCache is a CacheStore which keeps a bounded number of entries in memory, evicting the least recently used
+type MemoryCache struct {
+	// MaxEntries is the maximum number of entries held; 0 means no limit
+	MaxEntries int
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewMemoryCache returns an empty MemoryCache holding at most maxEntries entries
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{MaxEntries: maxEntries}
+}
+
+func (c *MemoryCache) init() {
+	if c.entries == nil {
+		c.lru = list.New()
+		c.entries = map[string]*list.Element{}
+	}
+}
+
+// Get implements CacheStore
+func (c *MemoryCache) Get(_ context.Context, key string) (*CacheEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.init()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+
+	c.lru.MoveToFront(el)
+
+	return el.Value.(*memoryCacheItem).entry, nil
+}
+
+// Set implements CacheStore
+func (c *MemoryCache) Set(_ context.Context, key string, entry *CacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.init()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*memoryCacheItem).entry = entry
+		c.lru.MoveToFront(el)
+
+		return nil
+	}
+
+	c.entries[key] = c.lru.PushFront(&memoryCacheItem{key: key, entry: entry})
+
+	for c.MaxEntries > 0 && c.lru.Len() > c.MaxEntries {
+		oldest := c.lru.Back()
+
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryCacheItem).key)
+	}
+
+	return nil
+}
+
+// Delete implements CacheStore
+func (c *MemoryCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.init()
+
+	if el, ok := c.entries[key]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+	}
+
+	return nil
+}
+
+// Len returns the number of entries held
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.init()
+
+	return c.lru.Len()
+}
+
+// DiskCache is a CacheStore which keeps each entry in a file in a directory on the local filesystem, so cached
+// responses survive restarts
+type DiskCache struct {
+	// Dir is the directory the entries are stored in
+	Dir string
+}
+
+// NewDiskCache returns a DiskCache which keeps entries in dir, creating it if needed
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil { // nolint: mnd
+		return nil, fmt.Errorf("error creating cache directory: %w", err)
+	}
+
+	return &DiskCache{Dir: dir}, nil
+}
+
+// path returns the file an entry is stored in; keys are hashed as they contain URLs
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:]))
+}
+
+// Get implements CacheStore
+func (c *DiskCache) Get(_ context.Context, key string) (*CacheEntry, error) {
+	b, err := os.ReadFile(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrCacheMiss
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache entry: %w", err)
+	}
+
+	entry := &CacheEntry{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		return nil, fmt.Errorf("error decoding cache entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// Set implements CacheStore; the entry is written to a temporary file first, so concurrent readers never see a
+// partially written entry
+func (c *DiskCache) Set(_ context.Context, key string, entry *CacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding cache entry: %w", err)
+	}
+
+	f, err := os.CreateTemp(c.Dir, "entry-*")
+	if err != nil {
+		return fmt.Errorf("error creating cache entry: %w", err)
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), c.path(key)); err != nil {
+		os.Remove(f.Name())
+
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+
+	return nil
+}
+
+// Delete implements CacheStore
+func (c *DiskCache) Delete(_ context.Context, key string) error {
+	if err := os.Remove(c.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting cache entry: %w", err)
+	}
+
+	return nil
+}
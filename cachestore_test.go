@@ -0,0 +1,137 @@
+package httpsling
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheStore(t *testing.T) {
+	disk, err := NewDiskCache(t.TempDir())
+	require.NoError(t, err)
+
+	stores := map[string]CacheStore{
+		"disk":   disk,
+		"memory": NewMemoryCache(10),
+	}
+
+	entry := &CacheEntry{
+		StatusCode:   http.StatusOK,
+		Header:       http.Header{HeaderETag: {`"v1"`}},
+		Body:         []byte("contents"),
+		VaryHeader:   http.Header{HeaderAccept: {ContentTypeJSON}},
+		RequestTime:  time.Now().Add(-time.Second).UTC(),
+		ResponseTime: time.Now().UTC(),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			key := "https://example.com/a?b=c"
+
+			_, err := store.Get(ctx, key)
+			require.ErrorIs(t, err, ErrCacheMiss)
+
+			require.NoError(t, store.Set(ctx, key, entry))
+
+			got, err := store.Get(ctx, key)
+			require.NoError(t, err)
+			assert.Equal(t, entry.StatusCode, got.StatusCode)
+			assert.Equal(t, entry.Header, got.Header)
+			assert.Equal(t, entry.Body, got.Body)
+			assert.Equal(t, entry.VaryHeader, got.VaryHeader)
+			assert.True(t, entry.ResponseTime.Equal(got.ResponseTime))
+
+			require.NoError(t, store.Delete(ctx, key))
+			require.NoError(t, store.Delete(ctx, key))
+
+			_, err = store.Get(ctx, key)
+			require.ErrorIs(t, err, ErrCacheMiss)
+		})
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(2)
+
+	require.NoError(t, c.Set(ctx, "a", &CacheEntry{}))
+	require.NoError(t, c.Set(ctx, "b", &CacheEntry{}))
+
+	// reading a makes b the least recently used entry
+	_, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+
+	require.NoError(t, c.Set(ctx, "c", &CacheEntry{}))
+
+	assert.Equal(t, 2, c.Len())
+
+	_, err = c.Get(ctx, "b")
+	require.ErrorIs(t, err, ErrCacheMiss)
+
+	_, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+}
+
+func TestCacheEntryFreshness(t *testing.T) {
+	now := time.Now()
+	date := now.Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		status   int
+		header   http.Header
+		expected time.Duration
+	}{
+		{
+			name:     "max-age",
+			status:   http.StatusOK,
+			header:   http.Header{HeaderCacheControl: {"public, max-age=60"}, HeaderExpires: {now.Add(time.Hour).Format(http.TimeFormat)}},
+			expected: time.Minute,
+		},
+		{
+			name:     "expires",
+			status:   http.StatusOK,
+			header:   http.Header{HeaderDate: {date.Format(http.TimeFormat)}, HeaderExpires: {date.Add(2 * time.Hour).Format(http.TimeFormat)}},
+			expected: 2 * time.Hour,
+		},
+		{
+			name:     "invalid expires",
+			status:   http.StatusOK,
+			header:   http.Header{HeaderExpires: {"0"}},
+			expected: 0,
+		},
+		{
+			name:     "heuristic",
+			status:   http.StatusOK,
+			header:   http.Header{HeaderDate: {date.Format(http.TimeFormat)}, HeaderLastModified: {date.Add(-10 * time.Hour).Format(http.TimeFormat)}},
+			expected: time.Hour,
+		},
+		{
+			name:     "no heuristic for other statuses",
+			status:   http.StatusCreated,
+			header:   http.Header{HeaderDate: {date.Format(http.TimeFormat)}, HeaderLastModified: {date.Add(-10 * time.Hour).Format(http.TimeFormat)}},
+			expected: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := &CacheEntry{StatusCode: test.status, Header: test.header, RequestTime: now, ResponseTime: now}
+			assert.Equal(t, test.expected, e.freshnessLifetime())
+		})
+	}
+
+	// the age accounts for the Age header and the time spent in flight
+	e := &CacheEntry{
+		Header:       http.Header{HeaderAge: {"30"}, HeaderDate: {now.Format(http.TimeFormat)}},
+		RequestTime:  now.Add(-2 * time.Second),
+		ResponseTime: now,
+	}
+
+	assert.Equal(t, 32*time.Second+time.Minute, e.age(now.Add(time.Minute)))
+}
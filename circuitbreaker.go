@@ -0,0 +1,311 @@
+package httpsling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultCircuitBreakerConfig is the default circuit breaker configuration used if nil is passed to CircuitBreaker()
+var DefaultCircuitBreakerConfig = CircuitBreakerConfig{}
+
+// KeyFunc returns the key a request is grouped under by middleware which keeps state per upstream
+type KeyFunc func(req *http.Request) string
+
+// HostKey is a KeyFunc grouping requests by the host (and port) of their URL
+func HostKey(req *http.Request) string {
+	return req.URL.Host
+}
+
+// CircuitState is the state of a circuit
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails requests immediately with ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe requests through to decide whether to close the circuit again
+	CircuitHalfOpen
+)
+
+// String implements fmt.Stringer
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitBreakerConfig defines settings for the CircuitBreaker middleware
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures which opens the circuit (default 5)
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before letting probe requests through (default 30s)
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of probe requests let through while half-open; the circuit closes once
+	// that many probes succeeded, and opens again as soon as one fails (default 1)
+	HalfOpenRequests int
+	// IsFailure tests whether the outcome of a request counts as a failure (default DefaultIsFailure); the
+	// attempt is the Retry attempt when the breaker is installed after Retry, otherwise 0. Errors which are not
+	// failures, such as a canceled context, count neither as a failure nor a success
+	IsFailure ShouldRetryer
+	// Key returns the key of the circuit a request belongs to (default HostKey)
+	Key KeyFunc
+	// IdleTimeout is how long a closed circuit is kept without requests before it is removed, so circuits of keys
+	// which are no longer requested don't accumulate (default 10 minutes); its count of failures is forgotten
+	IdleTimeout time.Duration
+	// OnStateChange is called when the circuit of key changes state
+	OnStateChange func(key string, from, to CircuitState)
+}
+
+func (c *CircuitBreakerConfig) normalize() {
+	if c.FailureThreshold < 1 {
+		c.FailureThreshold = 5
+	}
+
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 30 * time.Second // nolint: mnd
+	}
+
+	if c.HalfOpenRequests < 1 {
+		c.HalfOpenRequests = 1
+	}
+
+	if c.IsFailure == nil {
+		c.IsFailure = ShouldRetryerFunc(DefaultIsFailure)
+	}
+
+	if c.Key == nil {
+		c.Key = HostKey
+	}
+
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = 10 * time.Minute // nolint: mnd
+	}
+}
+
+// DefaultIsFailure counts every transport error except a canceled context as a failure of the upstream, so
+// refused connections and DNS failures open the circuit, as well as the responses DefaultShouldRetry retries
+func DefaultIsFailure(attempt int, req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+
+	return DefaultShouldRetry(attempt, req, resp, err)
+}
+
+// CircuitBreaker stops sending requests to an upstream which keeps failing: after FailureThreshold consecutive
+// failures the circuit of the upstream opens, and requests fail immediately with an error wrapping ErrCircuitOpen
+// until OpenTimeout has passed. The circuit then lets HalfOpenRequests probes through, and closes again if they succeed.
+// Each upstream, as returned by the Key function, has its own circuit
+func CircuitBreaker(config *CircuitBreakerConfig) Middleware {
+	c := DefaultCircuitBreakerConfig
+	if config != nil {
+		c = *config
+	}
+
+	c.normalize()
+
+	b := &breaker{config: c, circuits: map[string]*circuit{}}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			key := c.Key(req)
+			cb := b.circuit(key)
+
+			generation, err := b.allow(key, cb)
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := next.Do(req)
+
+			switch {
+			case c.IsFailure.ShouldRetry(RetryAttempt(req.Context()), req, resp, err):
+				b.done(key, cb, generation, outcomeFailure)
+			case err != nil:
+				b.done(key, cb, generation, outcomeNeutral)
+			default:
+				b.done(key, cb, generation, outcomeSuccess)
+			}
+
+			return resp, err
+		})
+	}
+}
+
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+	outcomeNeutral
+)
+
+// breaker holds the circuits of a CircuitBreaker middleware
+type breaker struct {
+	config CircuitBreakerConfig
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+	swept    time.Time
+}
+
+// circuit is the state of a single key
+type circuit struct {
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	successes int
+	probes    int
+	openedAt  time.Time
+	lastUsed  time.Time
+	// generation changes on every state change, so the outcome of requests sent in a previous state is ignored
+	generation uint64
+}
+
+func (b *breaker) circuit(key string) *circuit {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.swept) >= b.config.IdleTimeout {
+		b.evictIdle(now)
+	}
+
+	cb, ok := b.circuits[key]
+	if !ok {
+		cb = &circuit{lastUsed: now}
+		b.circuits[key] = cb
+	}
+
+	return cb
+}
+
+// evictIdle removes the closed circuits which haven't been used for IdleTimeout; open and half-open circuits are
+// kept, so a failing upstream can't escape its circuit by going quiet
+func (b *breaker) evictIdle(now time.Time) {
+	b.swept = now
+
+	for key, cb := range b.circuits {
+		cb.mu.Lock()
+		idle := cb.state == CircuitClosed && now.Sub(cb.lastUsed) >= b.config.IdleTimeout
+		cb.mu.Unlock()
+
+		if idle {
+			delete(b.circuits, key)
+		}
+	}
+}
+
+// allow returns the generation of the circuit if the request can be sent
+func (b *breaker) allow(key string, cb *circuit) (uint64, error) {
+	cb.mu.Lock()
+
+	cb.lastUsed = time.Now()
+
+	var changed *stateChange
+
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= b.config.OpenTimeout {
+		changed = cb.setState(CircuitHalfOpen)
+	}
+
+	var err error
+
+	switch {
+	case cb.state == CircuitOpen,
+		cb.state == CircuitHalfOpen && cb.probes >= b.config.HalfOpenRequests:
+		err = fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+	case cb.state == CircuitHalfOpen:
+		cb.probes++
+	}
+
+	generation := cb.generation
+	cb.mu.Unlock()
+
+	b.notify(key, changed)
+
+	return generation, err
+}
+
+// done records the outcome of a request sent in the given generation of the circuit
+func (b *breaker) done(key string, cb *circuit, generation uint64, o outcome) {
+	cb.mu.Lock()
+
+	cb.lastUsed = time.Now()
+
+	if generation != cb.generation {
+		cb.mu.Unlock()
+		return
+	}
+
+	var changed *stateChange
+
+	switch cb.state {
+	case CircuitClosed:
+		switch o {
+		case outcomeFailure:
+			cb.failures++
+			if cb.failures >= b.config.FailureThreshold {
+				changed = cb.setState(CircuitOpen)
+			}
+		case outcomeSuccess:
+			cb.failures = 0
+		}
+	case CircuitHalfOpen:
+		cb.probes--
+
+		switch o {
+		case outcomeFailure:
+			changed = cb.setState(CircuitOpen)
+		case outcomeSuccess:
+			cb.successes++
+			if cb.successes >= b.config.HalfOpenRequests {
+				changed = cb.setState(CircuitClosed)
+			}
+		}
+	}
+
+	cb.mu.Unlock()
+
+	b.notify(key, changed)
+}
+
+// notify calls OnStateChange for a transition returned by setState; it is called without holding the circuit lock
+func (b *breaker) notify(key string, changed *stateChange) {
+	if changed != nil && b.config.OnStateChange != nil {
+		b.config.OnStateChange(key, changed.from, changed.to)
+	}
+}
+
+// stateChange is a transition of a circuit from one state to another
+type stateChange struct {
+	from, to CircuitState
+}
+
+// setState moves the circuit to state, returning the transition
+func (cb *circuit) setState(state CircuitState) *stateChange {
+	from := cb.state
+
+	cb.state = state
+	cb.failures = 0
+	cb.successes = 0
+	cb.probes = 0
+	cb.generation++
+
+	if state == CircuitOpen {
+		cb.openedAt = time.Now()
+	}
+
+	return &stateChange{from: from, to: state}
+}
@@ -0,0 +1,212 @@
+package httpsling_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var status atomic.Int32
+
+	status.Store(http.StatusServiceUnavailable)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer s.Close()
+
+	var (
+		mu      sync.Mutex
+		changes []string
+	)
+
+	r := httptestutil.Requester(s, httpsling.CircuitBreaker(&httpsling.CircuitBreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      50 * time.Millisecond,
+		OnStateChange: func(_ string, from, to httpsling.CircuitState) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			changes = append(changes, fmt.Sprintf("%s->%s", from, to))
+		},
+	}))
+
+	i := httptestutil.Inspect(s)
+
+	// failed responses are still returned until the circuit opens
+	for range 2 {
+		resp, err := r.Receive(nil)
+		require.NoError(t, err)
+
+		resp.Body.Close()
+	}
+
+	_, err := r.Receive(nil) // nolint: bodyclose
+	require.ErrorIs(t, err, httpsling.ErrCircuitOpen)
+	assert.Len(t, i.Drain(), 2)
+
+	// after the timeout a failed probe opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+
+	resp, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	_, err = r.Receive(nil) // nolint: bodyclose
+	require.ErrorIs(t, err, httpsling.ErrCircuitOpen)
+
+	// a successful probe closes it
+	status.Store(http.StatusOK)
+	time.Sleep(60 * time.Millisecond)
+
+	for range 3 {
+		resp, err = r.Receive(nil)
+		require.NoError(t, err)
+
+		resp.Body.Close()
+	}
+
+	assert.Len(t, i.Drain(), 4)
+	assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}, changes)
+}
+
+func TestCircuitBreakerKeys(t *testing.T) {
+	failing := httptest.NewServer(httpsling.MockHandler(http.StatusInternalServerError))
+	defer failing.Close()
+
+	healthy := httptest.NewServer(httpsling.MockHandler(http.StatusOK))
+	defer healthy.Close()
+
+	r := httpsling.MustNew(httpsling.CircuitBreaker(&httpsling.CircuitBreakerConfig{FailureThreshold: 1}))
+
+	resp, err := r.Receive(nil, httpsling.Get(failing.URL))
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	_, err = r.Receive(nil, httpsling.Get(failing.URL)) // nolint: bodyclose
+	require.ErrorIs(t, err, httpsling.ErrCircuitOpen)
+
+	// the circuit of another host is unaffected
+	resp, err = r.Receive(nil, httpsling.Get(healthy.URL))
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	// a custom key puts both hosts in the same circuit
+	r = httpsling.MustNew(httpsling.CircuitBreaker(&httpsling.CircuitBreakerConfig{
+		FailureThreshold: 1,
+		Key:              func(*http.Request) string { return "all" },
+	}))
+
+	resp, err = r.Receive(nil, httpsling.Get(failing.URL))
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	_, err = r.Receive(nil, httpsling.Get(healthy.URL)) // nolint: bodyclose
+	require.ErrorIs(t, err, httpsling.ErrCircuitOpen)
+}
+
+func TestCircuitBreakerIdleTimeout(t *testing.T) {
+	failing := httptest.NewServer(httpsling.MockHandler(http.StatusInternalServerError))
+	defer failing.Close()
+
+	r := httpsling.MustNew(httpsling.Get(failing.URL), httpsling.CircuitBreaker(&httpsling.CircuitBreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Hour,
+		IdleTimeout:      20 * time.Millisecond,
+	}))
+
+	send := func() error {
+		resp, err := r.Receive(nil)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		return err
+	}
+
+	require.NoError(t, send())
+
+	// the closed circuit is removed while idle, forgetting its failure
+	time.Sleep(30 * time.Millisecond)
+
+	require.NoError(t, send())
+	require.NoError(t, send())
+	require.ErrorIs(t, send(), httpsling.ErrCircuitOpen)
+
+	// open circuits are kept
+	time.Sleep(30 * time.Millisecond)
+
+	require.ErrorIs(t, send(), httpsling.ErrCircuitOpen)
+}
+
+func TestCircuitBreakerIsFailure(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(http.StatusNotFound))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.CircuitBreaker(&httpsling.CircuitBreakerConfig{
+		FailureThreshold: 1,
+		IsFailure: httpsling.ShouldRetryerFunc(func(_ int, _ *http.Request, resp *http.Response, err error) bool {
+			return err != nil || resp.StatusCode == http.StatusNotFound
+		}),
+	}))
+
+	resp, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	_, err = r.Receive(nil) // nolint: bodyclose
+	require.ErrorIs(t, err, httpsling.ErrCircuitOpen)
+}
+
+func TestCircuitBreakerCanceled(t *testing.T) {
+	r := httpsling.MustNew(
+		httpsling.CircuitBreaker(&httpsling.CircuitBreakerConfig{FailureThreshold: 1}),
+		httpsling.WithDoer(httpsling.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, req.Context().Err()
+		})),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// a canceled request is not a failure of the upstream
+	for range 2 {
+		_, err := r.ReceiveWithContext(ctx, nil) // nolint: bodyclose
+		require.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+func TestCircuitBreakerConnectionRefused(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(200))
+	url := s.URL
+	s.Close()
+
+	r := httpsling.MustNew(httpsling.URL(url), httpsling.CircuitBreaker(&httpsling.CircuitBreakerConfig{FailureThreshold: 2}))
+
+	for range 2 {
+		_, err := r.Receive(nil) // nolint: bodyclose
+		require.Error(t, err)
+		require.NotErrorIs(t, err, httpsling.ErrCircuitOpen)
+	}
+
+	// a dead upstream opens the circuit
+	_, err := r.Receive(nil) // nolint: bodyclose
+	require.ErrorIs(t, err, httpsling.ErrCircuitOpen)
+}
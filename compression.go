@@ -0,0 +1,324 @@
+package httpsling
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encoders create compressing writers for the supported content encodings
+var encoders = map[string]func(io.Writer) (io.WriteCloser, error){
+	EncodingGzip: func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	},
+	EncodingDeflate: func(w io.Writer) (io.WriteCloser, error) {
+		return zlib.NewWriter(w), nil
+	},
+	EncodingBrotli: func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriter(w), nil
+	},
+	EncodingZstd: func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	},
+}
+
+// decoders create decompressing readers for the supported content encodings
+var decoders = map[string]func(*bufio.Reader) (io.ReadCloser, error){
+	EncodingGzip: func(r *bufio.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	EncodingDeflate: newDeflateReader,
+	EncodingBrotli: func(r *bufio.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(brotli.NewReader(r)), nil
+	},
+	EncodingZstd: func(r *bufio.Reader) (io.ReadCloser, error) {
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+
+		return d.IOReadCloser(), nil
+	},
+}
+
+// defaultAcceptEncodings are the encodings accepted by Decompress, in order of preference
+var defaultAcceptEncodings = []string{EncodingZstd, EncodingBrotli, EncodingGzip, EncodingDeflate}
+
+// newDeflateReader reads a deflate encoded body; the deflate content encoding is a zlib stream, but some servers
+// send raw deflate data, so the zlib header is checked first
+func newDeflateReader(r *bufio.Reader) (io.ReadCloser, error) {
+	header, err := r.Peek(2) // nolint: mnd
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(r)
+	}
+
+	return flate.NewReader(r), nil
+}
+
+// Compress compresses b with the content encoding
+func Compress(encoding string, b []byte) ([]byte, error) {
+	newWriter, ok := encoders[strings.ToLower(encoding)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentEncoding, encoding)
+	}
+
+	var buf bytes.Buffer
+
+	w, err := newWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(b); err != nil {
+		w.Close()
+
+		return nil, fmt.Errorf("error compressing body: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing body: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// CompressRequest compresses request bodies of at least minSize bytes with the content encoding, one of
+// EncodingGzip, EncodingDeflate, EncodingBrotli or EncodingZstd. The body is compressed in memory, and the request
+// gets a GetBody function replaying the compressed body, so it can still be retried. Requests which already have
+// a Content-Encoding are sent as they are
+func CompressRequest(encoding string, minSize int64) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Body == nil || req.Body == http.NoBody || req.Header.Get(HeaderContentEncoding) != "" ||
+				req.ContentLength > 0 && req.ContentLength < minSize {
+				return next.Do(req)
+			}
+
+			body, err := io.ReadAll(req.Body)
+			req.Body.Close()
+
+			if err != nil {
+				return nil, fmt.Errorf("error reading request body: %w", err)
+			}
+
+			compressed := *req
+			compressed.Header = req.Header.Clone()
+
+			if int64(len(body)) >= minSize {
+				if body, err = Compress(encoding, body); err != nil {
+					return nil, err
+				}
+
+				compressed.Header.Set(HeaderContentEncoding, strings.ToLower(encoding))
+				updateDigests(compressed.Header, body)
+			}
+
+			compressed.ContentLength = int64(len(body))
+			compressed.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(body)), nil
+			}
+			compressed.Body, _ = compressed.GetBody()
+
+			return next.Do(&compressed)
+		})
+	}
+}
+
+// DefaultMaxDecompressedSize is the default size above which decompressed response bodies are rejected
+const DefaultMaxDecompressedSize = 100 << 20
+
+// DecompressConfig defines settings for the Decompress middleware
+type DecompressConfig struct {
+	// Encodings are the accepted content encodings, in order of preference (default zstd, br, gzip and deflate)
+	Encodings []string
+	// MaxSize is the size above which reading a decompressed body fails with ErrDecompressedBodyTooLarge, to
+	// protect against decompression bombs (default DefaultMaxDecompressedSize); a negative value means no limit
+	MaxSize int64
+}
+
+func (c *DecompressConfig) normalize() {
+	if len(c.Encodings) == 0 {
+		c.Encodings = defaultAcceptEncodings
+	}
+
+	if c.MaxSize == 0 {
+		c.MaxSize = DefaultMaxDecompressedSize
+	}
+}
+
+// Decompress asks for compressed responses in the given content encodings (default zstd, br, gzip and deflate,
+// in that order of preference) unless the request already sets Accept-Encoding, and transparently decodes
+// responses using any of them. Unlike the automatic gzip support of http.Transport, it works with any Doer.
+// Decompressed bodies are limited to DefaultMaxDecompressedSize; use DecompressWith to change the limit
+func Decompress(encodings ...string) Middleware {
+	return DecompressWith(&DecompressConfig{Encodings: encodings})
+}
+
+// DecompressWith is Decompress with the given settings
+func DecompressWith(config *DecompressConfig) Middleware {
+	var c DecompressConfig
+	if config != nil {
+		c = *config
+	}
+
+	c.normalize()
+
+	accepted := map[string]bool{}
+	for _, encoding := range c.Encodings {
+		accepted[strings.ToLower(encoding)] = true
+	}
+
+	acceptEncoding := strings.Join(c.Encodings, ", ")
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(HeaderAcceptEncoding) == "" {
+				req = req.Clone(req.Context())
+				req.Header.Set(HeaderAcceptEncoding, acceptEncoding)
+			}
+
+			resp, err := next.Do(req)
+			if err != nil {
+				return resp, err
+			}
+
+			decodeContent(resp, accepted, c.MaxSize)
+
+			return resp, nil
+		})
+	}
+}
+
+// decodeContent replaces the body of the response with its decoded content, of at most maxSize bytes unless
+// maxSize is negative; responses using an encoding which isn't accepted are left untouched
+func decodeContent(resp *http.Response, accepted map[string]bool, maxSize int64) {
+	var codings []string
+
+	for _, coding := range headerTokens(resp.Header, HeaderContentEncoding) {
+		coding = strings.ToLower(coding)
+
+		switch {
+		case coding == "identity":
+			continue
+		case !accepted[coding] || decoders[coding] == nil:
+			return
+		}
+
+		codings = append(codings, coding)
+	}
+
+	if len(codings) == 0 {
+		return
+	}
+
+	body := &decodedBody{body: resp.Body, Reader: resp.Body}
+
+	// codings are listed in the order they were applied
+	for i := len(codings) - 1; i >= 0; i-- {
+		dec := &decodingReader{encoding: codings[i], src: bufio.NewReader(body.Reader)}
+
+		body.Reader = dec
+		body.decoders = append(body.decoders, dec)
+	}
+
+	if maxSize >= 0 {
+		body.Reader = &maxSizeReader{r: body.Reader, remaining: maxSize}
+	}
+
+	resp.Body = body
+	resp.Header.Del(HeaderContentEncoding)
+	resp.Header.Del(HeaderContentLength)
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decodingReader creates its decoder on the first read, so empty bodies, e.g. of HEAD requests, are not an error
+type decodingReader struct {
+	encoding string
+	src      *bufio.Reader
+	dec      io.ReadCloser
+	err      error
+}
+
+// Read implements io.Reader
+func (d *decodingReader) Read(p []byte) (int, error) {
+	if d.dec == nil && d.err == nil {
+		if _, err := d.src.Peek(1); err != nil {
+			return 0, err
+		}
+
+		if d.dec, d.err = decoders[d.encoding](d.src); d.err != nil {
+			d.err = fmt.Errorf("error decoding %s response body: %w", d.encoding, d.err)
+		}
+	}
+
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	return d.dec.Read(p)
+}
+
+// Close implements io.Closer
+func (d *decodingReader) Close() error {
+	if d.dec == nil {
+		return nil
+	}
+
+	return d.dec.Close()
+}
+
+// maxSizeReader fails with ErrDecompressedBodyTooLarge once more than the remaining bytes are read
+type maxSizeReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+// Read implements io.Reader
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	if m.remaining < 0 {
+		return 0, ErrDecompressedBodyTooLarge
+	}
+
+	// read one byte past the limit, to tell a body of exactly the maximum size from a larger one
+	if int64(len(p)) > m.remaining+1 {
+		p = p[:m.remaining+1]
+	}
+
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+
+	if m.remaining < 0 {
+		return n + int(m.remaining), ErrDecompressedBodyTooLarge
+	}
+
+	return n, err
+}
+
+// decodedBody reads the decoded content of a response body
+type decodedBody struct {
+	io.Reader
+	body     io.Closer
+	decoders []io.Closer
+}
+
+// Close closes the decoders and the original body
+func (b *decodedBody) Close() error {
+	errs := make([]error, 0, len(b.decoders)+1)
+
+	for _, dec := range b.decoders {
+		errs = append(errs, dec.Close())
+	}
+
+	return errors.Join(append(errs, b.body.Close())...)
+}
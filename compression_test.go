@@ -0,0 +1,238 @@
+package httpsling_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+var encodings = []string{httpsling.EncodingGzip, httpsling.EncodingDeflate, httpsling.EncodingBrotli, httpsling.EncodingZstd}
+
+func TestCompressRequest(t *testing.T) {
+	large := strings.Repeat("compress me ", 100)
+
+	for _, encoding := range encodings {
+		t.Run(encoding, func(t *testing.T) {
+			var (
+				gotEncoding string
+				gotBody     []string
+			)
+
+			// the server decodes the request body by running it through the Decompress middleware
+			s := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				gotEncoding = r.Header.Get(httpsling.HeaderContentEncoding)
+
+				resp := &http.Response{Header: http.Header{httpsling.HeaderContentEncoding: {gotEncoding}}, Body: r.Body}
+
+				_, err := httpsling.Decompress()(httpsling.DoerFunc(func(*http.Request) (*http.Response, error) {
+					return resp, nil
+				})).Do(r)
+				require.NoError(t, err)
+
+				b, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+
+				gotBody = append(gotBody, string(b))
+			}))
+			defer s.Close()
+
+			r := httptestutil.Requester(s,
+				httpsling.Retry(&httpsling.RetryConfig{
+					MaxAttempts: 2,
+					Backoff:     httpsling.NoBackoff(),
+					ShouldRetry: httpsling.ShouldRetryerFunc(func(attempt int, _ *http.Request, _ *http.Response, _ error) bool {
+						return attempt == 1
+					}),
+				}),
+				httpsling.CompressRequest(encoding, 64),
+			)
+
+			resp, err := r.Send(httpsling.Post(), httpsling.Body(large))
+			require.NoError(t, err)
+
+			resp.Body.Close()
+
+			// the compressed body is replayed on retries
+			assert.Equal(t, encoding, gotEncoding)
+			assert.Equal(t, []string{large, large}, gotBody)
+		})
+	}
+}
+
+func TestCompressRequestSmallBody(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(http.StatusOK))
+	defer s.Close()
+
+	i := httptestutil.Inspect(s)
+	r := httptestutil.Requester(s, httpsling.CompressRequest(httpsling.EncodingGzip, 64))
+
+	resp, err := r.Send(httpsling.Post(), httpsling.Body("small"))
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	ex := i.LastExchange()
+	assert.Empty(t, ex.Request.Header.Get(httpsling.HeaderContentEncoding))
+	assert.Equal(t, "small", ex.RequestBody.String())
+
+	r = httptestutil.Requester(s, httpsling.CompressRequest("unknown", 0))
+
+	_, err = r.Send(httpsling.Post(), httpsling.Body("a")) // nolint: bodyclose
+	require.ErrorIs(t, err, httpsling.ErrUnsupportedContentEncoding)
+}
+
+func TestDecompress(t *testing.T) {
+	for _, encoding := range encodings {
+		t.Run(encoding, func(t *testing.T) {
+			compressed, err := httpsling.Compress(encoding, []byte("decoded"))
+			require.NoError(t, err)
+
+			var acceptEncoding string
+
+			// the Doer is not an http.Client, so nothing else decodes the response
+			r := httpsling.MustNew(
+				httpsling.Decompress(),
+				httpsling.WithDoer(httpsling.DoerFunc(func(req *http.Request) (*http.Response, error) {
+					acceptEncoding = req.Header.Get(httpsling.HeaderAcceptEncoding)
+
+					return httpsling.MockResponse(http.StatusOK,
+						httpsling.Header(httpsling.HeaderContentEncoding, encoding),
+						httpsling.Header(httpsling.HeaderContentType, httpsling.ContentTypeText),
+						httpsling.Body(compressed),
+					), nil
+				})),
+			)
+
+			var out string
+
+			resp, err := r.Receive(&out, httpsling.Get("http://example.com"))
+			require.NoError(t, err)
+
+			assert.Equal(t, "zstd, br, gzip, deflate", acceptEncoding)
+			assert.Equal(t, "decoded", out)
+			assert.True(t, resp.Uncompressed)
+			assert.Empty(t, resp.Header.Get(httpsling.HeaderContentEncoding))
+		})
+	}
+}
+
+func TestDecompressServer(t *testing.T) {
+	compressed, err := httpsling.Compress(httpsling.EncodingBrotli, []byte("from the server"))
+	require.NoError(t, err)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httpsling.HeaderContentEncoding, httpsling.EncodingBrotli)
+		w.Header().Set(httpsling.HeaderContentType, httpsling.ContentTypeText)
+
+		if r.Method != http.MethodHead {
+			w.Write(compressed) // nolint: errcheck
+		}
+	}))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Decompress(httpsling.EncodingBrotli))
+
+	var out string
+
+	resp, err := r.Receive(&out)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, "from the server", out)
+
+	// an empty body is not an error
+	resp, err = r.Send(httpsling.Head())
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Empty(t, b)
+}
+
+func TestDecompressUnaccepted(t *testing.T) {
+	var buf bytes.Buffer
+
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("raw deflate"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	doer := httpsling.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return httpsling.MockResponse(http.StatusOK,
+			httpsling.Header(httpsling.HeaderContentEncoding, httpsling.EncodingDeflate),
+			httpsling.Body(buf.Bytes()),
+		), nil
+	})
+
+	// encodings which aren't accepted are left alone
+	resp, err := httpsling.Decompress(httpsling.EncodingGzip)(doer).Do(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, httpsling.EncodingDeflate, resp.Header.Get(httpsling.HeaderContentEncoding))
+
+	// raw deflate data is accepted as well as zlib streams
+	resp, err = httpsling.Decompress()(doer).Do(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "raw deflate", string(b))
+}
+
+func TestDecompressMaxSize(t *testing.T) {
+	compressed, err := httpsling.Compress(httpsling.EncodingGzip, bytes.Repeat([]byte{0}, 1<<20))
+	require.NoError(t, err)
+
+	doer := httpsling.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return httpsling.MockResponse(http.StatusOK,
+			httpsling.Header(httpsling.HeaderContentEncoding, httpsling.EncodingGzip),
+			httpsling.Body(compressed),
+		), nil
+	})
+
+	tests := []struct {
+		name    string
+		maxSize int64
+		wantErr bool
+	}{
+		{name: "exceeded", maxSize: 1<<20 - 1, wantErr: true},
+		{name: "exact", maxSize: 1 << 20},
+		{name: "unlimited", maxSize: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := httpsling.DecompressWith(&httpsling.DecompressConfig{MaxSize: tt.maxSize})
+
+			resp, err := m(doer).Do(httptest.NewRequest(http.MethodGet, "/", nil))
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			b, err := io.ReadAll(resp.Body)
+			if tt.wantErr {
+				require.ErrorIs(t, err, httpsling.ErrDecompressedBodyTooLarge)
+				assert.Len(t, b, int(tt.maxSize))
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Len(t, b, 1<<20)
+		})
+	}
+}
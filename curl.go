@@ -0,0 +1,586 @@
+package httpsling
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxInlineCurlBody is the size above which CurlCommand writes request bodies to a file
+const DefaultMaxInlineCurlBody = 4096
+
+// CurlConfig defines settings for CurlCommand
+type CurlConfig struct {
+	// Redactor, if set, removes secrets from the URL, headers and body of the command
+	Redactor *Redactor
+	// MaxInlineBody is the size above which bodies are written to a file and sent with --data-binary @file
+	// (default DefaultMaxInlineCurlBody); binary bodies are always written to a file. Inline bodies are sent with
+	// --data-raw, so a body starting with @ isn't read as a file name
+	MaxInlineBody int
+	// BodyDir is the directory body files are written to (default os.TempDir())
+	BodyDir string
+}
+
+// CurlCommand returns a curl command line which sends the same request, quoted for POSIX shells. Small text bodies
+// are passed inline with --data-raw, large and binary ones are written to a file in BodyDir, whose path is returned
+// as bodyFile so the caller can remove it once the command has been used. The request body can still be sent
+// afterwards
+func CurlCommand(req *http.Request, config *CurlConfig) (command, bodyFile string, err error) {
+	c := CurlConfig{}
+	if config != nil {
+		c = *config
+	}
+
+	if c.MaxInlineBody <= 0 {
+		c.MaxInlineBody = DefaultMaxInlineCurlBody
+	}
+
+	if c.Redactor == nil {
+		c.Redactor = &Redactor{NoDefaultHeaders: true}
+	}
+
+	data, peeked, err := peekRequestBody(req, -1)
+	if err != nil {
+		return "", "", fmt.Errorf("error reading request body: %w", err)
+	}
+
+	// peekRequestBody leaves req untouched if it has GetBody; otherwise its body is replaced by an equivalent one
+	if peeked != req {
+		req.Body = peeked.Body
+	}
+
+	rd := c.Redactor.compile()
+
+	command, err = curlCommand(rd, req, harBody{data: data}, func(body []byte) (string, error) {
+		if len(body) <= c.MaxInlineBody && !isBinary(body, false) {
+			return "", nil
+		}
+
+		f, err := os.CreateTemp(c.BodyDir, "httpsling-curl-*.body")
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := f.Write(body); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+
+			return "", err
+		}
+
+		if err := f.Close(); err != nil {
+			return "", err
+		}
+
+		bodyFile, err = filepath.Abs(f.Name())
+
+		return bodyFile, err
+	})
+	if err != nil && bodyFile != "" {
+		os.Remove(bodyFile)
+
+		bodyFile = ""
+	}
+
+	return command, bodyFile, err
+}
+
+// Curl builds the request with the options applied and returns it as a curl command line, see CurlCommand
+func (r *Requester) Curl(config *CurlConfig, opts ...Option) (command, bodyFile string, err error) {
+	req, err := r.RequestWithContext(context.Background(), opts...)
+	if err != nil {
+		return "", "", err
+	}
+
+	return CurlCommand(req, config)
+}
+
+// curlCommand returns a curl command line sending the request with the body, redacted with the redactor. bodyFile
+// may write the body to a file, returning its path; without one, or if it returns an empty path, text bodies are
+// inlined and binary ones are replaced by a shell comment noting their size
+func curlCommand(rd *redactor, req *http.Request, body harBody, bodyFile func([]byte) (string, error)) (string, error) {
+	args := []string{"curl"}
+
+	// curl sends a GET, or a POST if there is a body, unless told otherwise
+	implied := http.MethodGet
+	if len(body.data) > 0 {
+		implied = http.MethodPost
+	}
+
+	switch {
+	case req.Method == http.MethodHead:
+		// with -X HEAD curl waits for a response body which never comes
+		args = append(args, "-I")
+	case req.Method != "" && req.Method != implied:
+		args = append(args, "-X", shellQuote(req.Method))
+	}
+
+	args = append(args, shellQuote(rd.url(requestURL(req))))
+
+	header := rd.header(req.Header)
+	for _, key := range sortedHeaderKeys(header) {
+		for _, v := range header[key] {
+			args = append(args, "-H", shellQuote(key+": "+v))
+		}
+	}
+
+	if req.Host != "" && req.URL.Host != "" && req.Host != req.URL.Host {
+		args = append(args, "-H", shellQuote(HeaderHost+": "+req.Host))
+	}
+
+	if len(body.data) == 0 {
+		return strings.Join(args, " "), nil
+	}
+
+	redacted := rd.body(req.Header.Get(HeaderContentType), body.data)
+
+	if bodyFile != nil {
+		path, err := bodyFile(redacted)
+		if err != nil {
+			return "", fmt.Errorf("error writing request body: %w", err)
+		}
+
+		if path != "" {
+			return strings.Join(append(args, "--data-binary", shellQuote("@"+path)), " "), nil
+		}
+	}
+
+	if isBinary(body.data, body.truncated) {
+		return strings.Join(args, " ") + fmt.Sprintf(" # binary body of %d bytes omitted", len(body.data)), nil
+	}
+
+	command := strings.Join(append(args, "--data-raw", shellQuote(string(redacted))), " ")
+	if body.truncated {
+		command += " # body truncated"
+	}
+
+	return command, nil
+}
+
+// shellQuote quotes the argument for POSIX shells, if it needs quoting
+func shellQuote(arg string) string {
+	if arg != "" && strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+%", r))
+	}) == -1 {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// ParseCurl parses a curl command line into the Options which build the same request, e.g. to replay a request
+// copied from a browser in a test. It supports the common request options of curl: the URL, -X, -H, -d and its
+// --data-* variants, -F, -u, -A, -e, -b, -G and -I; options which only affect curl itself, like -s, -k or -L, are
+// ignored. Files referenced with @ are read when the command is parsed
+func ParseCurl(command string) ([]Option, error) {
+	words, err := shellWords(command)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(words) == 0 || filepath.Base(words[0]) != "curl" {
+		return nil, fmt.Errorf("%w: not a curl command", ErrInvalidCurlCommand)
+	}
+
+	p := curlParser{header: http.Header{}}
+
+	for i := 1; i < len(words); i++ {
+		word := words[i]
+
+		name, value, inline := curlFlag(word)
+
+		// combined short options without arguments, e.g. -sS
+		if !inline && len(name) > 2 && name[1] != '-' {
+			expanded := make([]string, 0, len(words)+len(name)-2)
+			expanded = append(expanded, words[:i]...)
+
+			for _, c := range name[1:] {
+				expanded = append(expanded, "-"+string(c))
+			}
+
+			words = append(expanded, words[i+1:]...)
+			i--
+
+			continue
+		}
+
+		if name == "" {
+			if p.url != "" {
+				return nil, fmt.Errorf("%w: more than one URL", ErrInvalidCurlCommand)
+			}
+
+			p.url = word
+
+			continue
+		}
+
+		arg := func() (string, error) {
+			if inline {
+				return value, nil
+			}
+
+			if i+1 >= len(words) {
+				return "", fmt.Errorf("%w: %s needs an argument", ErrInvalidCurlCommand, name)
+			}
+
+			i++
+
+			return words[i], nil
+		}
+
+		if err := p.flag(name, arg); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.options()
+}
+
+// curlFlag splits a command line word into a curl option and its inline argument, e.g. -XPOST or --data=x; words
+// which aren't options return an empty name
+func curlFlag(word string) (name, value string, inline bool) {
+	switch {
+	case strings.HasPrefix(word, "--"):
+		if name, value, ok := strings.Cut(word, "="); ok {
+			return name, value, true
+		}
+
+		return word, "", false
+	case strings.HasPrefix(word, "-") && len(word) > 1:
+		if len(word) > 2 && curlArgFlags[word[:2]] {
+			return word[:2], word[2:], true
+		}
+
+		return word, "", false
+	default:
+		return "", "", false
+	}
+}
+
+// curlArgFlags are the short curl options which take an argument
+var curlArgFlags = map[string]bool{"-X": true, "-H": true, "-d": true, "-F": true, "-u": true, "-A": true, "-e": true, "-b": true}
+
+// curlIgnoredFlags are curl options which don't change the request; the value is true if they take an argument
+var curlIgnoredFlags = map[string]bool{
+	"-s": false, "--silent": false, "-S": false, "--show-error": false, "-v": false, "--verbose": false,
+	"-k": false, "--insecure": false, "-L": false, "--location": false, "-i": false, "--include": false,
+	"--compressed": false, "-f": false, "--fail": false, "-o": true, "--output": true, "-m": true,
+	"--max-time": true, "--connect-timeout": true, "--retry": true,
+}
+
+// curlRequestFlags are the curl options which change the request
+var curlRequestFlags = map[string]bool{
+	"-G": true, "--get": true, "-I": true, "--head": true, "-X": true, "--request": true, "--url": true, "-H": true,
+	"--header": true, "-A": true, "--user-agent": true, "-e": true, "--referer": true, "-b": true, "--cookie": true,
+	"-u": true, "--user": true, "-d": true, "--data": true, "--data-ascii": true, "--data-binary": true,
+	"--data-raw": true, "--data-urlencode": true, "-F": true, "--form": true,
+}
+
+// curlParser accumulates the parsed curl options
+type curlParser struct {
+	method    string
+	url       string
+	header    http.Header
+	data      []string
+	form      *Multipart
+	get       bool
+	basicAuth *[2]string
+}
+
+func (p *curlParser) flag(name string, arg func() (string, error)) error { // nolint: gocyclo
+	if takesArg, ok := curlIgnoredFlags[name]; ok {
+		if takesArg {
+			_, err := arg()
+
+			return err
+		}
+
+		return nil
+	}
+
+	if !curlRequestFlags[name] {
+		return fmt.Errorf("%w: unsupported option %s", ErrInvalidCurlCommand, name)
+	}
+
+	switch name {
+	case "-G", "--get":
+		p.get = true
+
+		return nil
+	case "-I", "--head":
+		p.method = http.MethodHead
+
+		return nil
+	}
+
+	value, err := arg()
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case "-X", "--request":
+		p.method = value
+	case "--url":
+		p.url = value
+	case "-H", "--header":
+		key, v, ok := strings.Cut(value, ":")
+		if !ok {
+			return fmt.Errorf("%w: invalid header %q", ErrInvalidCurlCommand, value)
+		}
+
+		p.header.Add(strings.TrimSpace(key), strings.TrimSpace(v))
+	case "-A", "--user-agent":
+		p.header.Set(HeaderUserAgent, value)
+	case "-e", "--referer":
+		p.header.Set(HeaderReferer, value)
+	case "-b", "--cookie":
+		p.header.Add(HeaderCookie, value)
+	case "-u", "--user":
+		username, password, _ := strings.Cut(value, ":")
+		p.basicAuth = &[2]string{username, password}
+	case "-d", "--data", "--data-ascii", "--data-binary":
+		data, err := curlData(value, name == "--data-binary")
+		if err != nil {
+			return err
+		}
+
+		p.data = append(p.data, data)
+	case "--data-raw":
+		p.data = append(p.data, value)
+	case "--data-urlencode":
+		p.data = append(p.data, urlEncodeData(value))
+	case "-F", "--form":
+		return p.formPart(value)
+	}
+
+	return nil
+}
+
+// curlData returns the value of a --data option, reading it from the file if it starts with @; like curl, -d
+// strips newlines from files while --data-binary keeps them
+func curlData(value string, binary bool) (string, error) {
+	name, ok := strings.CutPrefix(value, "@")
+	if !ok {
+		return value, nil
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidCurlCommand, err)
+	}
+
+	if binary {
+		return string(b), nil
+	}
+
+	return strings.NewReplacer("\r", "", "\n", "").Replace(string(b)), nil
+}
+
+// urlEncodeData encodes a --data-urlencode value: "content", "=content" or "name=content"
+func urlEncodeData(value string) string {
+	name, content, ok := strings.Cut(value, "=")
+	if !ok {
+		return url.QueryEscape(value)
+	}
+
+	if name == "" {
+		return url.QueryEscape(content)
+	}
+
+	return name + "=" + url.QueryEscape(content)
+}
+
+// formPart adds a -F option, name=value or name=@file with optional ;type= and ;filename= attributes
+func (p *curlParser) formPart(value string) error {
+	name, content, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("%w: invalid form field %q", ErrInvalidCurlCommand, value)
+	}
+
+	if p.form == nil {
+		p.form = NewMultipart()
+	}
+
+	path, ok := strings.CutPrefix(content, "@")
+	if !ok {
+		p.form.AddField(name, content)
+
+		return nil
+	}
+
+	attrs := strings.Split(path, ";")
+
+	b, err := os.ReadFile(attrs[0])
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCurlCommand, err)
+	}
+
+	file := FileFromBytes(name, filepath.Base(attrs[0]), b)
+
+	for _, attr := range attrs[1:] {
+		key, v, _ := strings.Cut(attr, "=")
+
+		switch key {
+		case "type":
+			file.ContentType = v
+		case "filename":
+			file.FileName = v
+		}
+	}
+
+	p.form.AddFile(file)
+
+	return nil
+}
+
+// options returns the Options of the parsed command
+func (p *curlParser) options() ([]Option, error) {
+	if p.url == "" {
+		return nil, fmt.Errorf("%w: no URL", ErrInvalidCurlCommand)
+	}
+
+	rawURL := p.url
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	method := p.method
+	data := strings.Join(p.data, "&")
+
+	opts := []Option{URL(rawURL)}
+
+	switch {
+	case p.get && len(p.data) > 0:
+		opts = append(opts, queryString(data))
+	case len(p.data) > 0:
+		if method == "" {
+			method = http.MethodPost
+		}
+
+		opts = append(opts, Body([]byte(data)))
+
+		if p.header.Get(HeaderContentType) == "" {
+			opts = append(opts, ContentType(ContentTypeForm))
+		}
+	case p.form != nil:
+		if method == "" {
+			method = http.MethodPost
+		}
+
+		opts = append(opts, p.form)
+	}
+
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	opts = append(opts, Method(method))
+
+	for _, key := range sortedHeaderKeys(p.header) {
+		for _, v := range p.header[key] {
+			opts = append(opts, AddHeader(key, v))
+		}
+	}
+
+	if p.basicAuth != nil {
+		opts = append(opts, BasicAuth(p.basicAuth[0], p.basicAuth[1]))
+	}
+
+	return opts, nil
+}
+
+// queryString adds the encoded query parameters to the request
+func queryString(query string) Option {
+	return OptionFunc(func(r *Requester) error {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidCurlCommand, err)
+		}
+
+		if r.QueryParams == nil {
+			r.QueryParams = url.Values{}
+		}
+
+		for key, vs := range values {
+			r.QueryParams[key] = append(r.QueryParams[key], vs...)
+		}
+
+		return nil
+	})
+}
+
+// shellWords splits a command line into words like a POSIX shell, handling quotes, escapes and line continuations
+func shellWords(command string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range command {
+		switch {
+		case escaped:
+			escaped = false
+
+			// inside double quotes, a backslash only escapes characters which are special there
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", r) {
+				word.WriteRune('\\')
+			}
+
+			// a backslash newline continues the line
+			if r != '\n' {
+				word.WriteRune(r)
+
+				inWord = true
+			}
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("%w: unterminated quote or escape", ErrInvalidCurlCommand)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
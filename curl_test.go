@@ -0,0 +1,298 @@
+package httpsling_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+func TestCurlCommand(t *testing.T) {
+	r := httpsling.MustNew(httpsling.URL("https://api.example.com/v1/"))
+
+	tests := []struct {
+		name   string
+		config *httpsling.CurlConfig
+		opts   []httpsling.Option
+		want   string
+	}{
+		{
+			name: "get",
+			opts: []httpsling.Option{httpsling.Get("things"), httpsling.QueryParam("q", "a b")},
+			want: "curl 'https://api.example.com/v1/things?q=a+b'",
+		},
+		{
+			name: "post json",
+			opts: []httpsling.Option{httpsling.Post("things"), httpsling.Body(map[string]string{"name": "it's"})},
+			want: `curl https://api.example.com/v1/things -H 'Content-Type: application/json;charset=utf-8' --data-raw '{"name":"it'\''s"}'`,
+		},
+		{
+			name: "put",
+			opts: []httpsling.Option{httpsling.Put("things/1"), httpsling.Body("x"), httpsling.Header("X-Thing", "$HOME")},
+			want: `curl -X PUT https://api.example.com/v1/things/1 -H 'X-Thing: $HOME' --data-raw x`,
+		},
+		{
+			name: "delete",
+			opts: []httpsling.Option{httpsling.Delete("things/1")},
+			want: "curl -X DELETE https://api.example.com/v1/things/1",
+		},
+		{
+			name: "head",
+			opts: []httpsling.Option{httpsling.Head("things/1")},
+			want: "curl -I https://api.example.com/v1/things/1",
+		},
+		{
+			name:   "redacted",
+			config: &httpsling.CurlConfig{Redactor: &httpsling.Redactor{QueryParams: []string{"key"}}},
+			opts:   []httpsling.Option{httpsling.Get("things"), httpsling.QueryParam("key", "secret"), httpsling.BearerAuth("secret")},
+			want:   "curl 'https://api.example.com/v1/things?key=[REDACTED]' -H 'Authorization: [REDACTED]'",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			command, bodyFile, err := r.Curl(tc.config, tc.opts...)
+			require.NoError(t, err)
+			assert.Empty(t, bodyFile)
+			assert.Equal(t, tc.want, command)
+		})
+	}
+}
+
+func TestCurlCommandBodyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{name: "large", body: []byte(strings.Repeat("a", 100))},
+		{name: "binary", body: []byte{0x00, 0x01, 0xff}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := httpsling.MustNew(httpsling.Post("http://example.com"), httpsling.Body(tc.body)).Request()
+			require.NoError(t, err)
+
+			command, path, err := httpsling.CurlCommand(req, &httpsling.CurlConfig{MaxInlineBody: 10, BodyDir: dir})
+			require.NoError(t, err)
+
+			assert.Equal(t, "curl http://example.com --data-binary @"+path, command)
+			assert.Equal(t, dir, filepath.Dir(path))
+
+			b, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, b)
+
+			// the request can still be sent with its body
+			b, err = io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, b)
+		})
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	dir := t.TempDir()
+	dataFile := filepath.Join(dir, "data.txt")
+	require.NoError(t, os.WriteFile(dataFile, []byte("a=1\nb=2\n"), 0o600))
+
+	tests := []struct {
+		name    string
+		command string
+		method  string
+		uri     string
+		header  http.Header
+		body    string
+	}{
+		{
+			name:    "get",
+			command: `curl 'http://HOST/things?q=1' -H 'Accept: application/json' --compressed -sS`,
+			method:  "GET",
+			uri:     "/things?q=1",
+			header:  http.Header{"Accept": {"application/json"}},
+		},
+		{
+			name: "post with continuations",
+			command: `curl -X POST "http://HOST/things" \
+  -H "Content-Type: application/json" \
+  --data-raw '{"name":"it'\''s \"quoted\""}'`,
+			method: "POST",
+			uri:    "/things",
+			header: http.Header{"Content-Type": {"application/json"}},
+			body:   `{"name":"it's \"quoted\""}`,
+		},
+		{
+			name:    "form data",
+			command: `curl http://HOST/login -d user=cat -d pass=meow -u cat:meow -A agent`,
+			method:  "POST",
+			uri:     "/login",
+			header:  http.Header{"Content-Type": {httpsling.ContentTypeForm}, "Authorization": {"Basic Y2F0Om1lb3c="}, "User-Agent": {"agent"}},
+			body:    "user=cat&pass=meow",
+		},
+		{
+			name:    "data from file",
+			command: `curl -XPUT http://HOST/file -d @` + dataFile + ` --data-urlencode 'c=a b'`,
+			method:  "PUT",
+			uri:     "/file",
+			body:    "a=1b=2&c=a+b",
+		},
+		{
+			name:    "get with data",
+			command: `curl -G http://HOST/search --data-urlencode 'q=cats & dogs'`,
+			method:  "GET",
+			uri:     "/search?q=cats+%26+dogs",
+		},
+		{
+			name:    "head",
+			command: `curl -I http://HOST/`,
+			method:  "HEAD",
+			uri:     "/",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := httptest.NewServer(httpsling.MockHandler(200))
+			defer s.Close()
+
+			i := httptestutil.Inspect(s)
+
+			opts, err := httpsling.ParseCurl(strings.ReplaceAll(tc.command, "http://HOST", s.URL))
+			require.NoError(t, err)
+
+			resp, err := httpsling.Send(opts...)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			ex := i.LastExchange()
+			require.NotNil(t, ex)
+
+			assert.Equal(t, tc.method, ex.Request.Method)
+			assert.Equal(t, tc.uri, ex.Request.RequestURI)
+
+			for key := range tc.header {
+				assert.Equal(t, tc.header.Get(key), ex.Request.Header.Get(key), key)
+			}
+
+			if tc.body != "" {
+				assert.Equal(t, tc.body, ex.RequestBody.String())
+			}
+		})
+	}
+}
+
+func TestParseCurlForm(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "report.txt"), []byte("contents"), 0o600))
+
+	var (
+		name, contentType, contents string
+	)
+
+	s := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		f, h, err := r.FormFile("file")
+		if err != nil {
+			return
+		}
+
+		defer f.Close()
+
+		b, _ := io.ReadAll(f)
+		contents = string(b)
+		name = r.FormValue("name")
+		contentType = h.Header.Get(httpsling.HeaderContentType)
+	}))
+	defer s.Close()
+
+	opts, err := httpsling.ParseCurl(`curl ` + s.URL + ` -F name=quarterly -F 'file=@` + filepath.Join(dir, "report.txt") + `;type=text/x-report'`)
+	require.NoError(t, err)
+
+	resp, err := httpsling.Send(opts...)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "quarterly", name)
+	assert.Equal(t, "contents", contents)
+	assert.Equal(t, "text/x-report", contentType)
+}
+
+func TestCurlCommandAtBody(t *testing.T) {
+	// inline bodies starting with @ aren't read as file names by curl
+	command, _, err := httpsling.MustNew(httpsling.Post("http://example.com"), httpsling.Body("@secrets")).Curl(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "curl http://example.com --data-raw @secrets", command)
+
+	opts, err := httpsling.ParseCurl(command)
+	require.NoError(t, err)
+
+	req, err := httpsling.Request(opts...)
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "@secrets", string(b))
+}
+
+func TestParseCurlRoundTrip(t *testing.T) {
+	r := httpsling.MustNew(httpsling.URL("http://example.com/api/"), httpsling.Patch("things/1"),
+		httpsling.Header("X-Quote", `it's "quoted"`), httpsling.Body(map[string]string{"a": "b"}))
+
+	command, _, err := r.Curl(nil)
+	require.NoError(t, err)
+
+	opts, err := httpsling.ParseCurl(command)
+	require.NoError(t, err)
+
+	req, err := httpsling.Request(opts...)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodPatch, req.Method)
+	assert.Equal(t, "http://example.com/api/things/1", req.URL.String())
+	assert.Equal(t, `it's "quoted"`, req.Header.Get("X-Quote"))
+	assert.Contains(t, req.Header.Get(httpsling.HeaderContentType), httpsling.ContentTypeJSON)
+
+	b, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a":"b"}`, string(b))
+}
+
+func TestParseCurlRoundTripHead(t *testing.T) {
+	command, _, err := httpsling.MustNew(httpsling.Head("http://example.com/things/1")).Curl(nil)
+	require.NoError(t, err)
+
+	opts, err := httpsling.ParseCurl(command)
+	require.NoError(t, err)
+
+	req, err := httpsling.Request(opts...)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodHead, req.Method)
+	assert.Equal(t, "http://example.com/things/1", req.URL.String())
+}
+
+func TestParseCurlErrors(t *testing.T) {
+	for _, command := range []string{
+		"wget http://example.com",
+		"curl",
+		"curl 'http://example.com",
+		"curl http://example.com -H",
+		"curl http://example.com --unknown",
+		"curl http://example.com http://example.org",
+		"curl http://example.com -H novalue",
+		"curl http://example.com -F file=@/does/not/exist",
+	} {
+		_, err := httpsling.ParseCurl(command)
+		assert.ErrorIs(t, err, httpsling.ErrInvalidCurlCommand, command)
+	}
+}
@@ -0,0 +1,262 @@
+package httpsling
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Digest algorithms (RFC 9530 section 5)
+const (
+	DigestSHA256 = "sha-256"
+	DigestSHA512 = "sha-512"
+)
+
+// digestHashes are the supported digest algorithms
+var digestHashes = map[string]func() hash.Hash{
+	DigestSHA256: sha256.New,
+	DigestSHA512: sha512.New,
+}
+
+// Digest returns the value of a Content-Digest or Repr-Digest header for the body, e.g.
+// "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"; algorithms defaults to DigestSHA256
+func Digest(body []byte, algorithms ...string) (string, error) {
+	if len(algorithms) == 0 {
+		algorithms = []string{DigestSHA256}
+	}
+
+	members := make([]string, 0, len(algorithms))
+
+	for _, algorithm := range algorithms {
+		algorithm = strings.ToLower(algorithm)
+
+		newHash, ok := digestHashes[algorithm]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedDigestAlgorithm, algorithm)
+		}
+
+		h := newHash()
+		h.Write(body)
+
+		members = append(members, algorithm+"=:"+base64.StdEncoding.EncodeToString(h.Sum(nil))+":")
+	}
+
+	return strings.Join(members, ", "), nil
+}
+
+// ContentDigest adds a Content-Digest header (RFC 9530) to requests with a body, computed over the body as sent
+// using the given algorithms (default DigestSHA256). The body is buffered to compute the digest; CompressRequest
+// updates the digest after compressing the body
+func ContentDigest(algorithms ...string) Option {
+	return digestOption(HeaderContentDigest, algorithms)
+}
+
+// ReprDigest adds a Repr-Digest header (RFC 9530) to requests with a body; for a request sending the whole
+// representation this is the same as the Content-Digest
+func ReprDigest(algorithms ...string) Option {
+	return digestOption(HeaderReprDigest, algorithms)
+}
+
+func digestOption(header string, algorithms []string) Option {
+	if len(algorithms) == 0 {
+		algorithms = []string{DigestSHA256}
+	}
+
+	return OptionFunc(func(r *Requester) error {
+		if _, err := Digest(nil, algorithms...); err != nil {
+			return err
+		}
+
+		if r.digests == nil {
+			r.digests = map[string][]string{}
+		}
+
+		r.digests[header] = algorithms
+
+		return nil
+	})
+}
+
+// digestHeaders computes the digest headers of the body
+func digestHeaders(digests map[string][]string, body []byte) (http.Header, error) {
+	header := http.Header{}
+
+	for key, algorithms := range digests {
+		value, err := Digest(body, algorithms...)
+		if err != nil {
+			return nil, err
+		}
+
+		header.Set(key, value)
+	}
+
+	return header, nil
+}
+
+// updateDigests recomputes the digest headers of the request for the new body, using the same algorithms
+func updateDigests(header http.Header, body []byte) {
+	for _, key := range []string{HeaderContentDigest, HeaderReprDigest} {
+		var algorithms []string
+
+		for algorithm := range parseDictionary(header.Values(key)) {
+			if digestHashes[algorithm] != nil {
+				algorithms = append(algorithms, algorithm)
+			}
+		}
+
+		if len(algorithms) == 0 {
+			continue
+		}
+
+		value, _ := Digest(body, sortedAlgorithms(algorithms)...)
+		header.Set(key, value)
+	}
+}
+
+// sortedAlgorithms orders the algorithms strongest first
+func sortedAlgorithms(algorithms []string) []string {
+	sorted := make([]string, 0, len(algorithms))
+
+	for _, algorithm := range []string{DigestSHA512, DigestSHA256} {
+		for _, a := range algorithms {
+			if a == algorithm {
+				sorted = append(sorted, a)
+			}
+		}
+	}
+
+	return sorted
+}
+
+// DigestError is returned when reading a response body which doesn't match its digest
+type DigestError struct {
+	// Header is the header holding the digest, Content-Digest or Repr-Digest
+	Header string
+	// Algorithm is the digest algorithm
+	Algorithm string
+	// Expected is the digest sent with the response
+	Expected []byte
+	// Actual is the digest of the received body
+	Actual []byte
+}
+
+// Error implements error
+func (e *DigestError) Error() string {
+	return fmt.Sprintf("%s: %s %s is %s, received body has %s", ErrDigestMismatch, e.Header, e.Algorithm,
+		base64.StdEncoding.EncodeToString(e.Expected), base64.StdEncoding.EncodeToString(e.Actual))
+}
+
+// Unwrap returns ErrDigestMismatch
+func (e *DigestError) Unwrap() error {
+	return ErrDigestMismatch
+}
+
+// VerifyDigest verifies response bodies against their Content-Digest and Repr-Digest headers (RFC 9530); reading a
+// body which doesn't match fails with a *DigestError when the end of the body is reached, so Receive returns it.
+// Responses without a supported digest are passed through. Install it inside Decompress, since digests cover the
+// encoded content
+func VerifyDigest() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.Do(req)
+			if err != nil || resp.Body == nil || resp.Body == http.NoBody || resp.Uncompressed ||
+				req.Method == http.MethodHead || resp.StatusCode == http.StatusNotModified {
+				return resp, err
+			}
+
+			headers := []string{HeaderContentDigest}
+			if resp.StatusCode != http.StatusPartialContent {
+				// Repr-Digest covers the whole representation, not the range of a partial response
+				headers = append(headers, HeaderReprDigest)
+			}
+
+			verifier := &digestVerifier{ReadCloser: resp.Body}
+
+			for _, key := range headers {
+				verifier.digests = append(verifier.digests, parseDigests(resp.Header, key)...)
+			}
+
+			if len(verifier.digests) > 0 {
+				resp.Body = verifier
+			}
+
+			return resp, nil
+		})
+	}
+}
+
+// parseDigests returns the digests of the header with a supported algorithm, ready to hash the body
+func parseDigests(h http.Header, key string) []digest {
+	var digests []digest
+
+	for algorithm, value := range parseDictionary(h.Values(key)) {
+		newHash := digestHashes[algorithm]
+		if newHash == nil {
+			continue
+		}
+
+		expected, ok := strings.CutPrefix(value, ":")
+		expected, ok2 := strings.CutSuffix(expected, ":")
+		decoded, decodeErr := base64.StdEncoding.DecodeString(expected)
+
+		if !ok || !ok2 || decodeErr != nil {
+			decoded = nil // a malformed digest never matches
+		}
+
+		digests = append(digests, digest{header: key, algorithm: algorithm, expected: decoded, hash: newHash()})
+	}
+
+	return digests
+}
+
+type digest struct {
+	header    string
+	algorithm string
+	expected  []byte
+	hash      hash.Hash
+}
+
+// verify returns a *DigestError if the hashed body doesn't match the digest
+func (d digest) verify() error {
+	if actual := d.hash.Sum(nil); !bytes.Equal(actual, d.expected) {
+		return &DigestError{Header: d.header, Algorithm: d.algorithm, Expected: d.expected, Actual: actual}
+	}
+
+	return nil
+}
+
+// digestVerifier hashes the body as it is read, checking the digests at the end of the body
+type digestVerifier struct {
+	io.ReadCloser
+	digests []digest
+	err     error
+}
+
+// Read implements io.Reader
+func (v *digestVerifier) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+
+	n, err := v.ReadCloser.Read(p)
+
+	for _, d := range v.digests {
+		d.hash.Write(p[:n])
+	}
+
+	if err == io.EOF {
+		for _, d := range v.digests {
+			if v.err = d.verify(); v.err != nil {
+				return n, v.err
+			}
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,148 @@
+package httpsling_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+func TestDigest(t *testing.T) {
+	// RFC 9530 appendix D.1
+	d, err := httpsling.Digest([]byte(`{"hello": "world"}`+"\n"), httpsling.DigestSHA256, httpsling.DigestSHA512)
+	require.NoError(t, err)
+	assert.Equal(t, "sha-256=:RK/0qy18MlBSVnWgjwz6lZEWjP/lF5HF9bvEF8FabDg=:, "+
+		"sha-512=:YMAam51Jz/jOATT6/zvHrLVgOYTGFy1d6GJiOHTohq4yP+pgk4vf2aCsyRZOtw8MjkM7iw7yZ/WkppmM44T3qg==:", d)
+
+	_, err = httpsling.Digest(nil, "md5")
+	assert.ErrorIs(t, err, httpsling.ErrUnsupportedDigestAlgorithm)
+}
+
+func TestContentDigest(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(201))
+	defer s.Close()
+
+	i := httptestutil.Inspect(s)
+
+	r := httptestutil.Requester(s, httpsling.ContentDigest(), httpsling.ReprDigest(httpsling.DigestSHA256))
+
+	resp, err := r.Send(httpsling.Post(), httpsling.Body(map[string]string{"color": "red"}))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	ex := i.LastExchange()
+	require.NotNil(t, ex)
+
+	body := ex.RequestBody.Bytes()
+	assert.JSONEq(t, `{"color":"red"}`, string(body))
+	assert.Equal(t, sha256Digest(body), ex.Request.Header.Get(httpsling.HeaderContentDigest))
+	assert.Equal(t, sha256Digest(body), ex.Request.Header.Get(httpsling.HeaderReprDigest))
+
+	// requests without a body have no digest
+	resp, err = r.Send(httpsling.Get())
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Empty(t, i.LastExchange().Request.Header.Get(httpsling.HeaderContentDigest))
+
+	_, err = httpsling.New(httpsling.ContentDigest("md5"))
+	assert.ErrorIs(t, err, httpsling.ErrUnsupportedDigestAlgorithm)
+}
+
+func TestContentDigestCompressed(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(201))
+	defer s.Close()
+
+	i := httptestutil.Inspect(s)
+
+	r := httptestutil.Requester(s, httpsling.ContentDigest(), httpsling.CompressRequest(httpsling.EncodingGzip, 0))
+
+	resp, err := r.Send(httpsling.Post(), httpsling.Body("compress me"))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	ex := i.LastExchange()
+	require.NotNil(t, ex)
+
+	assert.Equal(t, httpsling.EncodingGzip, ex.Request.Header.Get(httpsling.HeaderContentEncoding))
+	assert.Equal(t, sha256Digest(ex.RequestBody.Bytes()), ex.Request.Header.Get(httpsling.HeaderContentDigest))
+}
+
+func TestVerifyDigest(t *testing.T) {
+	body := []byte("digest me")
+
+	tests := []struct {
+		name    string
+		status  int
+		header  string
+		digest  string
+		wantErr bool
+	}{
+		{name: "content digest", status: 200, header: httpsling.HeaderContentDigest, digest: sha256Digest(body)},
+		{name: "repr digest", status: 200, header: httpsling.HeaderReprDigest, digest: sha256Digest(body)},
+		{name: "mismatch", status: 200, header: httpsling.HeaderContentDigest, digest: sha256Digest([]byte("other")), wantErr: true},
+		{name: "malformed", status: 200, header: httpsling.HeaderContentDigest, digest: "sha-256=notbase64", wantErr: true},
+		{name: "repr digest mismatch", status: 200, header: httpsling.HeaderReprDigest, digest: sha256Digest([]byte("other")), wantErr: true},
+		{name: "repr digest of partial content", status: 206, header: httpsling.HeaderReprDigest, digest: sha256Digest([]byte("other"))},
+		{name: "unsupported algorithm", status: 200, header: httpsling.HeaderContentDigest, digest: "md5=:AAAA:"},
+		{name: "no digest", status: 200},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []httpsling.Option{httpsling.Body(body)}
+			if tc.header != "" {
+				opts = append(opts, httpsling.Header(tc.header, tc.digest))
+			}
+
+			s := httptest.NewServer(httpsling.MockHandler(tc.status, opts...))
+			defer s.Close()
+
+			_, err := httptestutil.Requester(s, httpsling.VerifyDigest()).Receive(nil)
+
+			if !tc.wantErr {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.ErrorIs(t, err, httpsling.ErrDigestMismatch)
+
+			var digestErr *httpsling.DigestError
+			require.True(t, errors.As(err, &digestErr))
+			assert.Equal(t, tc.header, digestErr.Header)
+			assert.Equal(t, httpsling.DigestSHA256, digestErr.Algorithm)
+		})
+	}
+}
+
+func TestVerifyDigestStreaming(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(200,
+		httpsling.Body("streamed"),
+		httpsling.Header(httpsling.HeaderContentDigest, sha256Digest([]byte("other")))))
+	defer s.Close()
+
+	resp, err := httptestutil.Requester(s, httpsling.VerifyDigest()).Send()
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	require.ErrorIs(t, err, httpsling.ErrDigestMismatch)
+	assert.True(t, bytes.Equal([]byte("streamed"), b))
+}
@@ -0,0 +1,296 @@
+package httpsling
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DumpFormat is the output format of a Dumper
+type DumpFormat int
+
+const (
+	// DumpHTTP dumps requests and responses in HTTP/1.1 wire format
+	DumpHTTP DumpFormat = iota
+	// DumpCurl dumps requests as curl command lines, and responses in HTTP/1.1 wire format
+	DumpCurl
+	// DumpHAR dumps each exchange as a HAR 1.2 entry in JSON
+	DumpHAR
+)
+
+// BinaryFormat selects how a Dumper outputs bodies which aren't text
+type BinaryFormat int
+
+const (
+	// BinaryElide replaces binary bodies with a note of their size
+	BinaryElide BinaryFormat = iota
+	// BinaryHex dumps binary bodies like hexdump -C
+	BinaryHex
+	// BinaryRaw dumps binary bodies as they are
+	BinaryRaw
+)
+
+// DumpConfig defines settings for dumping requests and responses
+type DumpConfig struct {
+	// Format is the output format (default DumpHTTP)
+	Format DumpFormat
+	// Redactor removes secrets from the dumped URLs, headers and bodies; nil redacts DefaultRedactedHeaders
+	Redactor *Redactor
+	// MaxBodySize is the maximum number of body bytes dumped; 0 dumps whole bodies
+	MaxBodySize int64
+	// Binary selects how binary bodies are dumped in the DumpHTTP and DumpCurl formats (default BinaryElide); HAR
+	// entries always base64 encode them
+	Binary BinaryFormat
+	// Pretty indents JSON and XML bodies, and HAR entries
+	Pretty bool
+}
+
+// Dumper writes requests and responses to a writer; it is shared by the Dump middleware and the server side
+// dumping of httptestutil
+type Dumper struct {
+	w  io.Writer
+	c  DumpConfig
+	rd *redactor
+	mu sync.Mutex
+}
+
+// NewDumper returns a Dumper writing to w
+func NewDumper(w io.Writer, config *DumpConfig) *Dumper {
+	d := &Dumper{w: w}
+	if config != nil {
+		d.c = *config
+	}
+
+	d.rd = d.c.Redactor.compile()
+
+	return d
+}
+
+// Exchange dumps the request, sends it with next, and dumps the response; bodies are buffered up to MaxBodySize
+// and can still be read in full. Requests received by a server, which have a RequestURI, are dumped as received
+func (d *Dumper) Exchange(req *http.Request, next Doer) (*http.Response, error) {
+	limit := d.c.MaxBodySize
+	if limit <= 0 {
+		limit = -1
+	}
+
+	started := time.Now()
+
+	data, peeked, err := peekRequestBody(req, limit)
+	if err == nil {
+		req = peeked
+	}
+
+	reqBody := capturedBody(data, limit)
+
+	switch d.c.Format {
+	case DumpHTTP:
+		d.write(d.httpRequest(req, reqBody))
+	case DumpCurl:
+		command, _ := curlCommand(d.rd, req, reqBody, nil)
+		d.write(command + "\n")
+	}
+
+	resp, err := next.Do(req)
+	wait := time.Since(started)
+
+	var respBody harBody
+
+	if resp != nil && resp.Body != nil && resp.Body != http.NoBody {
+		data, resp.Body = peekBody(resp.Body, limit)
+		respBody = capturedBody(data, limit)
+	}
+
+	switch {
+	case d.c.Format == DumpHAR:
+		entry := newHAREntry(d.rd, req, reqBody, resp, respBody, started, wait, 0)
+		if err != nil {
+			entry.Comment = errorComment(d.rd, req, err)
+		}
+
+		d.write(d.harEntry(entry))
+	case resp != nil:
+		d.write(d.httpResponse(resp, respBody))
+	}
+
+	return resp, err
+}
+
+// capturedBody returns the captured part of a body, which has been truncated if more than limit bytes were read
+func capturedBody(data []byte, limit int64) harBody {
+	if limit >= 0 && int64(len(data)) > limit {
+		return harBody{data: data[:limit], truncated: true}
+	}
+
+	return harBody{data: data}
+}
+
+func (d *Dumper) write(s string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	io.WriteString(d.w, s) // nolint: errcheck
+}
+
+// httpRequest dumps the request in wire format
+func (d *Dumper) httpRequest(req *http.Request, body harBody) string {
+	redacted := *req
+	redacted.Header = d.rd.header(req.Header)
+	redacted.URL = redactedRequestURL(d.rd, req)
+
+	var (
+		head []byte
+		err  error
+	)
+
+	if req.RequestURI != "" {
+		redacted.RequestURI = redacted.URL.RequestURI()
+		head, err = httputil.DumpRequest(&redacted, false)
+	} else {
+		head, err = httputil.DumpRequestOut(&redacted, false)
+	}
+
+	if err != nil {
+		return "Error dumping request: " + err.Error() + "\n"
+	}
+
+	return string(head) + d.body(req.Header.Get(HeaderContentType), body) + "\n"
+}
+
+// httpResponse dumps the response in wire format
+func (d *Dumper) httpResponse(resp *http.Response, body harBody) string {
+	redacted := *resp
+	redacted.Header = d.rd.header(resp.Header)
+
+	head, err := httputil.DumpResponse(&redacted, false)
+	if err != nil {
+		return "Error dumping response: " + err.Error() + "\n"
+	}
+
+	return string(head) + d.body(resp.Header.Get(HeaderContentType), body) + "\n"
+}
+
+// harEntry dumps the entry as JSON
+func (d *Dumper) harEntry(entry *HAREntry) string {
+	var (
+		b   []byte
+		err error
+	)
+
+	if d.c.Pretty {
+		b, err = json.MarshalIndent(entry, "", "  ")
+	} else {
+		b, err = json.Marshal(entry)
+	}
+
+	if err != nil {
+		return "Error dumping HAR entry: " + err.Error() + "\n"
+	}
+
+	return string(b) + "\n"
+}
+
+// body formats a body for the wire format
+func (d *Dumper) body(contentType string, body harBody) string {
+	if len(body.data) == 0 {
+		return ""
+	}
+
+	var text string
+
+	switch {
+	case d.c.Binary == BinaryRaw || !isBinary(body.data, body.truncated):
+		text = string(d.rd.body(contentType, body.data))
+
+		if d.c.Pretty && !body.truncated {
+			text = prettyBody(contentType, text)
+		}
+	case d.c.Binary == BinaryHex:
+		text = hex.Dump(body.data)
+	default:
+		text = fmt.Sprintf("[binary body of %d bytes]", len(body.data))
+	}
+
+	if body.truncated {
+		text += "\n...(truncated)"
+	}
+
+	return text
+}
+
+// prettyBody indents JSON and XML bodies, returning other bodies and bodies which fail to parse as they are
+func prettyBody(contentType, body string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case mediaType == ContentTypeJSON || strings.HasSuffix(mediaType, "+json"):
+		var buf bytes.Buffer
+		if json.Indent(&buf, []byte(body), "", "  ") == nil {
+			return buf.String()
+		}
+	case mediaType == ContentTypeXML || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		if pretty, err := indentXML(body); err == nil {
+			return pretty
+		}
+	}
+
+	return body
+}
+
+// indentXML re-encodes the XML document with indentation
+func indentXML(body string) (string, error) {
+	var buf bytes.Buffer
+
+	dec := xml.NewDecoder(strings.NewReader(body))
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		// whitespace between elements is replaced by the indentation
+		if data, ok := token.(xml.CharData); ok && len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+
+		if err := enc.EncodeToken(token); err != nil {
+			return "", err
+		}
+	}
+
+	if err := enc.Flush(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// DumpWith dumps requests and responses to a writer with the given settings
+func DumpWith(w io.Writer, config *DumpConfig) Middleware {
+	d := NewDumper(w, config)
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return d.Exchange(req, next)
+		})
+	}
+}
+
+// rawDumpConfig dumps everything as it is, without redaction
+var rawDumpConfig = &DumpConfig{Redactor: &Redactor{NoDefaultHeaders: true}, Binary: BinaryRaw}
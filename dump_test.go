@@ -0,0 +1,200 @@
+package httpsling_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+func TestDumpWithRedaction(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(200,
+		httpsling.Body(map[string]string{"token": "secret-token"}),
+		httpsling.Header(httpsling.HeaderSetCookie, "session=secret-session")))
+	defer s.Close()
+
+	var buf bytes.Buffer
+
+	r := httptestutil.Requester(s, httpsling.DumpWith(&buf, &httpsling.DumpConfig{
+		Redactor: &httpsling.Redactor{
+			Headers:     httpsling.DefaultRedactedHeaders,
+			QueryParams: []string{"key"},
+			BodyFields:  []string{"password", "token"},
+		},
+	}))
+
+	var out map[string]string
+
+	_, err := r.Receive(&out, httpsling.Post("/login"), httpsling.QueryParam("key", "secret-key"),
+		httpsling.BasicAuth("cat", "secret-password"), httpsling.Body(map[string]string{"password": "secret-password"}))
+	require.NoError(t, err)
+	assert.Equal(t, "secret-token", out["token"])
+
+	dump := buf.String()
+	assert.NotContains(t, dump, "secret")
+	assert.Contains(t, dump, "POST /login?key=[REDACTED] HTTP/1.1")
+	assert.Contains(t, dump, "Authorization: [REDACTED]")
+	assert.Contains(t, dump, `{"password":"[REDACTED]"}`)
+	assert.Contains(t, dump, "HTTP/1.1 200 OK")
+	assert.Contains(t, dump, "Set-Cookie: [REDACTED]")
+	assert.Contains(t, dump, `{"token":"[REDACTED]"}`)
+}
+
+func TestDumpWithBodies(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', 0x00, 0x01, 0x02, 0xff}
+
+	tests := []struct {
+		name     string
+		config   *httpsling.DumpConfig
+		ct       string
+		body     string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "truncated",
+			config:   &httpsling.DumpConfig{MaxBodySize: 5},
+			ct:       httpsling.ContentTypeText,
+			body:     "0123456789",
+			contains: []string{"01234\n...(truncated)"},
+			excludes: []string{"56789"},
+		},
+		{
+			name:     "binary elided",
+			config:   &httpsling.DumpConfig{},
+			ct:       "image/png",
+			body:     string(binary),
+			contains: []string{"[binary body of 8 bytes]"},
+			excludes: []string{"PNG"},
+		},
+		{
+			name:     "binary hex",
+			config:   &httpsling.DumpConfig{Binary: httpsling.BinaryHex},
+			ct:       "image/png",
+			body:     string(binary),
+			contains: []string{"00000000  89 50 4e 47 00 01 02 ff", "|.PNG....|"},
+		},
+		{
+			name:     "pretty json",
+			config:   &httpsling.DumpConfig{Pretty: true},
+			ct:       httpsling.ContentTypeJSON,
+			body:     `{"a":{"b":1}}`,
+			contains: []string{"{\n  \"a\": {\n    \"b\": 1\n  }\n}"},
+		},
+		{
+			name:     "pretty xml",
+			config:   &httpsling.DumpConfig{Pretty: true},
+			ct:       httpsling.ContentTypeXML,
+			body:     `<a><b>1</b></a>`,
+			contains: []string{"<a>\n  <b>1</b>\n</a>"},
+		},
+		{
+			name:     "invalid json is dumped as is",
+			config:   &httpsling.DumpConfig{Pretty: true},
+			ct:       httpsling.ContentTypeJSON,
+			body:     `{"a":`,
+			contains: []string{`{"a":`},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := httptest.NewServer(httpsling.MockHandler(200, httpsling.Body(tc.body), httpsling.ContentType(tc.ct)))
+			defer s.Close()
+
+			var buf bytes.Buffer
+
+			resp, err := httptestutil.Requester(s, httpsling.DumpWith(&buf, tc.config)).Send(httpsling.Get())
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			// the body can still be read in full
+			b, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, string(b))
+
+			for _, s := range tc.contains {
+				assert.Contains(t, buf.String(), s)
+			}
+
+			for _, s := range tc.excludes {
+				assert.NotContains(t, buf.String(), s)
+			}
+		})
+	}
+}
+
+func TestDumpWithCurl(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(201))
+	defer s.Close()
+
+	var buf bytes.Buffer
+
+	r := httptestutil.Requester(s, httpsling.DumpWith(&buf, &httpsling.DumpConfig{Format: httpsling.DumpCurl}))
+
+	_, err := r.Receive(nil, httpsling.Put("/things/1"), httpsling.BearerAuth("secret"), httpsling.Body("it's"))
+	require.NoError(t, err)
+
+	lines := strings.SplitN(buf.String(), "\n", 2)
+	assert.Equal(t, "curl -X PUT "+s.URL+"/things/1 -H 'Authorization: [REDACTED]' --data-raw 'it'\\''s'", lines[0])
+	assert.Contains(t, lines[1], "HTTP/1.1 201 Created")
+}
+
+func TestDumpWithHAR(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G', 0x00}
+
+	s := httptest.NewServer(httpsling.MockHandler(200, httpsling.Body(png), httpsling.ContentType("image/png")))
+	defer s.Close()
+
+	var buf bytes.Buffer
+
+	r := httptestutil.Requester(s, httpsling.DumpWith(&buf, &httpsling.DumpConfig{Format: httpsling.DumpHAR}))
+
+	_, err := r.Receive(nil, httpsling.Post("/upload"), httpsling.QueryParam("q", "1"),
+		httpsling.Header(httpsling.HeaderCookie, "session=secret"), httpsling.Body("hello"), httpsling.ContentType(httpsling.ContentTypeText))
+	require.NoError(t, err)
+
+	var entry httpsling.HAREntry
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+
+	assert.Equal(t, "POST", entry.Request.Method)
+	assert.Equal(t, s.URL+"/upload?q=1", entry.Request.URL)
+	assert.Equal(t, []httpsling.HARNameValue{{Name: "q", Value: "1"}}, entry.Request.QueryString)
+	assert.Equal(t, []httpsling.HARCookie{{Name: "session", Value: httpsling.Redacted}}, entry.Request.Cookies)
+	require.NotNil(t, entry.Request.PostData)
+	assert.Equal(t, "hello", entry.Request.PostData.Text)
+	assert.EqualValues(t, 5, entry.Request.BodySize)
+
+	assert.Equal(t, 200, entry.Response.Status)
+	assert.Equal(t, "image/png", entry.Response.Content.MimeType)
+	assert.Equal(t, "base64", entry.Response.Content.Encoding)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(png), entry.Response.Content.Text)
+	assert.Positive(t, entry.Timings.Wait)
+	assert.False(t, entry.StartedDateTime.IsZero())
+}
+
+func TestDumpWithError(t *testing.T) {
+	var buf bytes.Buffer
+
+	r := httpsling.MustNew(httpsling.WithDoer(httpsling.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return nil, io.ErrUnexpectedEOF
+	})), httpsling.DumpWith(&buf, &httpsling.DumpConfig{Format: httpsling.DumpHAR}))
+
+	_, err := r.Send(httpsling.Get("http://example.com"))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	var entry httpsling.HAREntry
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, io.ErrUnexpectedEOF.Error(), entry.Comment)
+}
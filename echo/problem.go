@@ -0,0 +1,12 @@
+package echoform
+
+import (
+	echo "github.com/theopenlane/echox"
+
+	"github.com/theopenlane/httpsling"
+)
+
+// WriteProblem writes an RFC 9457 problem document as the response, using the same format as httpsling.WriteProblem
+func WriteProblem(c echo.Context, p *httpsling.Problem) error {
+	return httpsling.WriteProblem(c.Response(), p)
+}
@@ -0,0 +1,37 @@
+package echoform_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	echo "github.com/theopenlane/echox"
+
+	"github.com/theopenlane/httpsling"
+	echoform "github.com/theopenlane/httpsling/echo"
+)
+
+func TestWriteProblem(t *testing.T) {
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return echoform.WriteProblem(c, httpsling.NewProblem(http.StatusConflict, "name is taken"))
+	})
+
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	resp, err := httpsling.Receive(httpsling.Get(ts.URL), httpsling.ProblemErrors())
+	require.Error(t, err)
+
+	defer resp.Body.Close()
+
+	var problem *httpsling.Problem
+
+	require.True(t, errors.As(err, &problem))
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "Conflict", problem.Title)
+	assert.Equal(t, "name is taken", problem.Detail)
+}
@@ -13,4 +13,49 @@ var (
 	ErrNoFilesUploaded = errors.New("no uploadable files found in request")
 	// ErrUnsupportedMimeType is returned when the mime type is unsupported
 	ErrUnsupportedMimeType = errors.New("unsupported mime type")
+	// ErrFileTooLarge is returned when an uploaded file exceeds the maximum file size
+	ErrFileTooLarge = errors.New("uploaded file exceeds the maximum file size")
+	// ErrUploadTooLarge is returned when a multipart form request exceeds the maximum upload size
+	ErrUploadTooLarge = errors.New("upload exceeds the maximum upload size")
+	// ErrFileNotFound is returned when a file does not exist in a Storage
+	ErrFileNotFound = errors.New("file not found")
+	// ErrFileStorageFailed is returned when an uploaded file could not be persisted to its Storage
+	ErrFileStorageFailed = errors.New("failed to store uploaded file")
+	// ErrInvalidStorageURL is returned when a storage URL has an invalid signature or has expired
+	ErrInvalidStorageURL = errors.New("invalid storage url")
+	// ErrStorageKeyExists is returned when an uploaded file would replace an object which is already stored
+	ErrStorageKeyExists = errors.New("storage key already exists")
+	// ErrInvalidStorageKey is returned when a Storage key can't be used
+	ErrInvalidStorageKey = errors.New("invalid storage key")
+	// ErrCircuitOpen is returned by the CircuitBreaker middleware while the circuit of the upstream is open
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrBulkheadFull is returned by the Bulkhead middleware when too many requests are in flight and waiting
+	ErrBulkheadFull = errors.New("bulkhead is full")
+	// ErrCacheMiss is returned by a CacheStore when no entry is stored under a key
+	ErrCacheMiss = errors.New("cache miss")
+	// ErrUnsupportedContentEncoding is returned when a body can't be compressed with the requested content encoding
+	ErrUnsupportedContentEncoding = errors.New("unsupported content encoding")
+	// ErrDecompressedBodyTooLarge is returned when reading a response body which decompresses to more than the
+	// maximum size allowed by the Decompress middleware
+	ErrDecompressedBodyTooLarge = errors.New("decompressed body too large")
+	// ErrMissingAccessToken is returned when a token endpoint responds without an access token
+	ErrMissingAccessToken = errors.New("token response has no access token")
+	// ErrUnsupportedSignatureKey is returned when a key can't be used for HTTP message signatures
+	ErrUnsupportedSignatureKey = errors.New("unsupported signature key")
+	// ErrMissingSignature is returned when a request has no HTTP message signature
+	ErrMissingSignature = errors.New("missing http message signature")
+	// ErrInvalidSignature is returned when the HTTP message signature of a request can't be verified
+	ErrInvalidSignature = errors.New("invalid http message signature")
+	// ErrSignatureExpired is returned when an HTTP message signature is too old or past its expiry
+	ErrSignatureExpired = errors.New("http message signature expired")
+	// ErrUnsupportedDigestAlgorithm is returned when a digest is requested with an unknown algorithm
+	ErrUnsupportedDigestAlgorithm = errors.New("unsupported digest algorithm")
+	// ErrDigestMismatch is returned when a received body doesn't match its Content-Digest or Repr-Digest
+	ErrDigestMismatch = errors.New("digest mismatch")
+	// ErrInvalidCurlCommand is returned when a curl command line can't be parsed
+	ErrInvalidCurlCommand = errors.New("invalid curl command")
+	// ErrNoHAREntry is returned by a HARReplayer when no recorded entry matches a request
+	ErrNoHAREntry = errors.New("no matching HAR entry")
+	// ErrRecordedFailure is returned by a HARReplayer when it replays an entry recorded with a transport error
+	ErrRecordedFailure = errors.New("recorded request failed")
 )
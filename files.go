@@ -2,6 +2,7 @@ package httpsling
 
 import (
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"strings"
 )
@@ -15,10 +16,28 @@ type File struct {
 	FieldName string `json:"field_name,omitempty"`
 	// OriginalName is he name of the file from the client side / which was sent in the request
 	OriginalName string `json:"original_name,omitempty"`
+	// UploadedFileName is the name of the file after the NameGeneratorFunc has been applied
+	UploadedFileName string `json:"uploaded_file_name,omitempty"`
 	// MimeType of the uploaded file
 	MimeType string `json:"mime_type,omitempty"`
 	// Size in bytes of the uploaded file
 	Size int64 `json:"size,omitempty"`
+	// StorageKey is the key the file was stored under, when the upload was persisted to a Storage
+	StorageKey string `json:"storage_key,omitempty"`
+	// Checksum is the hex encoded sha256 checksum of the file contents, when the upload was persisted to a Storage
+	Checksum string `json:"checksum,omitempty"`
+
+	// header is the parsed multipart file header, used to read the contents of the file
+	header *multipart.FileHeader
+}
+
+// Open opens the contents of an uploaded file
+func (f File) Open() (multipart.File, error) {
+	if f.header == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoFilesUploaded, f.OriginalName)
+	}
+
+	return f.header.Open()
 }
 
 // ValidationFunc is a type that can be used to dynamically validate a file
@@ -3,16 +3,27 @@ module github.com/theopenlane/httpsling
 go 1.23.5
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	github.com/felixge/httpsnoop v1.0.4
 	github.com/google/go-querystring v1.1.0
+	github.com/klauspost/compress v1.18.0
 	github.com/stretchr/testify v1.11.1
-	github.com/theopenlane/utils v0.4.4
+	go.opentelemetry.io/otel v1.35.0
+	go.opentelemetry.io/otel/metric v1.35.0
+	go.opentelemetry.io/otel/sdk v1.35.0
+	go.opentelemetry.io/otel/sdk/metric v1.35.0
+	go.opentelemetry.io/otel/trace v1.35.0
 )
 
 require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
 	go.uber.org/mock v0.5.0 // indirect
 	golang.org/x/mod v0.18.0 // indirect
 	golang.org/x/sync v0.10.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/tools v0.22.0 // indirect
 )
 
@@ -21,5 +32,5 @@ require (
 	github.com/mazrean/formstream v1.1.2
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
 	github.com/theopenlane/echox v0.2.1
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
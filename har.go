@@ -0,0 +1,390 @@
+package httpsling
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// HARVersion is the version of the HAR format written by HARRecorder
+const HARVersion = "1.2"
+
+// HAR is an HTTP Archive (http://www.softwareishard.com/blog/har-12-spec/)
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the log of an HTTP Archive
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+	Comment string     `json:"comment,omitempty"`
+}
+
+// HARCreator is the application which created an HTTP Archive
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// ReadHAR decodes an HTTP Archive
+func ReadHAR(r io.Reader) (*HAR, error) {
+	var har HAR
+
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("decoding HAR: %w", err)
+	}
+
+	return &har, nil
+}
+
+// LoadHAR reads an HTTP Archive from a file
+func LoadHAR(path string) (*HAR, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return ReadHAR(f)
+}
+
+// WriteTo writes the HTTP Archive as indented JSON
+func (h *HAR) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(append(b, '\n'))
+
+	return int64(n), err
+}
+
+// Save writes the HTTP Archive to a file
+func (h *HAR) Save(path string) error {
+	var buf bytes.Buffer
+
+	if _, err := h.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0o644) // nolint: gosec
+}
+
+// HAREntry is an HTTP exchange in the HAR 1.2 format (http://www.softwareishard.com/blog/har-12-spec/)
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARRequest is a request in the HAR format
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARResponse is a response in the HAR format
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARNameValue is a header or query parameter in the HAR format
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARCookie is a cookie in the HAR format
+type HARCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+// HARPostData is a request body in the HAR format; like HARContent, binary bodies are base64 encoded with
+// Encoding set to "base64"
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARContent is a response body in the HAR format
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARTimings are the timings of an exchange in the HAR format, in milliseconds; -1 means not measured
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// harBody is a captured body, which may have been truncated
+type harBody struct {
+	data      []byte
+	truncated bool
+}
+
+// harText decodes the text of a HAR body
+func harText(text, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+
+	return []byte(text), nil
+}
+
+// text returns the body as HAR text, base64 encoded if it is binary
+func (b harBody) text(rd *redactor, contentType string) (text, encoding string) {
+	if isBinary(b.data, b.truncated) {
+		return base64.StdEncoding.EncodeToString(b.data), "base64"
+	}
+
+	return string(rd.body(contentType, b.data)), ""
+}
+
+// newHAREntry builds the HAR entry of an exchange, redacting it with the redactor
+func newHAREntry(rd *redactor, req *http.Request, reqBody harBody, resp *http.Response, respBody harBody,
+	started time.Time, wait, receive time.Duration) *HAREntry {
+	entry := &HAREntry{
+		StartedDateTime: started,
+		Time:            milliseconds(wait + receive),
+		Request: HARRequest{
+			Method:      req.Method,
+			URL:         rd.url(requestURL(req)),
+			HTTPVersion: httpVersion(req.Proto),
+			Cookies:     harCookies(rd, req.Header, req.Cookies()),
+			Headers:     harHeaders(rd.header(req.Header)),
+			QueryString: []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    req.ContentLength,
+		},
+		Timings: HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: milliseconds(wait), Receive: milliseconds(receive)},
+	}
+
+	if entry.Request.BodySize < 0 {
+		entry.Request.BodySize = int64(len(reqBody.data))
+	}
+
+	redactedURL := redactedRequestURL(rd, req)
+	for key, values := range redactedURL.Query() {
+		for _, v := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, HARNameValue{Name: key, Value: v})
+		}
+	}
+
+	sort.SliceStable(entry.Request.QueryString, func(i, j int) bool {
+		return entry.Request.QueryString[i].Name < entry.Request.QueryString[j].Name
+	})
+
+	if len(reqBody.data) > 0 {
+		contentType := req.Header.Get(HeaderContentType)
+		text, encoding := reqBody.text(rd, contentType)
+
+		entry.Request.PostData = &HARPostData{MimeType: contentType, Text: text, Encoding: encoding, Comment: truncatedComment(reqBody)}
+	}
+
+	if resp == nil {
+		entry.Response = HARResponse{Cookies: []HARCookie{}, Headers: []HARNameValue{}, HeadersSize: -1, BodySize: -1}
+
+		return entry
+	}
+
+	contentType := resp.Header.Get(HeaderContentType)
+	text, encoding := respBody.text(rd, contentType)
+
+	entry.Response = HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: httpVersion(resp.Proto),
+		Cookies:     harCookies(rd, resp.Header, resp.Cookies()),
+		Headers:     harHeaders(rd.header(resp.Header)),
+		Content: HARContent{
+			Size:     int64(len(respBody.data)),
+			MimeType: contentType,
+			Text:     text,
+			Encoding: encoding,
+			Comment:  truncatedComment(respBody),
+		},
+		RedirectURL: resp.Header.Get(HeaderLocation),
+		HeadersSize: -1,
+		BodySize:    resp.ContentLength,
+	}
+
+	if entry.Response.BodySize < 0 {
+		entry.Response.BodySize = int64(len(respBody.data))
+	}
+
+	return entry
+}
+
+// errorComment returns the message of a transport error with the request URL redacted; the message of a *url.Error
+// includes the URL, query and credentials
+func errorComment(rd *redactor, req *http.Request, err error) string {
+	msg := err.Error()
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+			msg = strings.ReplaceAll(msg, urlErr.URL, rd.url(u))
+		}
+	}
+
+	return strings.ReplaceAll(msg, req.URL.String(), rd.url(req.URL))
+}
+
+func truncatedComment(b harBody) string {
+	if b.truncated {
+		return "truncated"
+	}
+
+	return ""
+}
+
+// harHeaders returns the headers in order
+func harHeaders(h http.Header) []HARNameValue {
+	headers := []HARNameValue{}
+
+	for _, key := range sortedHeaderKeys(h) {
+		for _, v := range h[key] {
+			headers = append(headers, HARNameValue{Name: key, Value: v})
+		}
+	}
+
+	return headers
+}
+
+// harCookies converts cookies, redacting their values if the header they were sent in is redacted
+func harCookies(rd *redactor, h http.Header, cookies []*http.Cookie) []HARCookie {
+	redact := rd.headers[HeaderCookie] && len(h.Values(HeaderCookie)) > 0 ||
+		rd.headers[HeaderSetCookie] && len(h.Values(HeaderSetCookie)) > 0
+
+	harCookies := make([]HARCookie, 0, len(cookies))
+
+	for _, c := range cookies {
+		hc := HARCookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure}
+
+		if redact {
+			hc.Value = Redacted
+		}
+
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			hc.Expires = &expires
+		}
+
+		harCookies = append(harCookies, hc)
+	}
+
+	return harCookies
+}
+
+// httpVersion returns the protocol of a message, defaulting to HTTP/1.1 for requests which haven't been sent
+func httpVersion(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+
+	return proto
+}
+
+// requestURL returns the absolute URL of the request; requests received by a server only have the request URI
+func requestURL(req *http.Request) *url.URL {
+	u := *req.URL
+
+	if u.Host == "" {
+		u.Host = req.Host
+	}
+
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if req.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+
+	return &u
+}
+
+// redactedRequestURL returns the absolute URL of the request with redacted query parameters and credentials
+func redactedRequestURL(rd *redactor, req *http.Request) *url.URL {
+	u, err := url.Parse(rd.url(requestURL(req)))
+	if err != nil {
+		return requestURL(req)
+	}
+
+	return u
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// isBinary returns true if the body isn't text; the end of a truncated body may cut a UTF-8 sequence short
+func isBinary(body []byte, truncated bool) bool {
+	if truncated {
+		for i := 0; i < utf8.UTFMax-1 && len(body) > 0 && !utf8.Valid(body); i++ {
+			body = body[:len(body)-1]
+		}
+	}
+
+	if !utf8.Valid(body) {
+		return true
+	}
+
+	for _, b := range body {
+		if b < 0x20 && !strings.ContainsRune("\t\n\r\f", rune(b)) || b == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+package httpsling
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RecordConfig defines settings for a HARRecorder
+type RecordConfig struct {
+	// Redactor removes secrets from the recorded URLs, headers and bodies; nil redacts DefaultRedactedHeaders.
+	// Recorded query parameters whose value is Redacted match any value when the HAR is replayed
+	Redactor *Redactor
+	// MaxBodySize is the maximum number of body bytes recorded; 0 records whole bodies
+	MaxBodySize int64
+	// Comment is written to the log of the HAR
+	Comment string
+}
+
+// HARRecorder is a Requester Option which records the exchanges of a Requester as HAR 1.2 entries, to be saved and
+// replayed with a HARReplayer. Response bodies are read in full before the response is returned, so the timings of
+// each entry are split between waiting for the response headers and receiving the body
+type HARRecorder struct {
+	c       RecordConfig
+	rd      *redactor
+	mu      sync.Mutex
+	entries []HAREntry
+}
+
+// NewHARRecorder returns a HARRecorder
+func NewHARRecorder(config *RecordConfig) *HARRecorder {
+	h := &HARRecorder{}
+	if config != nil {
+		h.c = *config
+	}
+
+	h.rd = h.c.Redactor.compile()
+
+	return h
+}
+
+// Apply implements Option
+func (h *HARRecorder) Apply(r *Requester) error {
+	return r.Apply(Middleware(h.Wrap))
+}
+
+// Wrap implements Middleware
+func (h *HARRecorder) Wrap(next Doer) Doer {
+	return DoerFunc(func(req *http.Request) (*http.Response, error) {
+		limit := h.c.MaxBodySize
+		if limit <= 0 {
+			limit = -1
+		}
+
+		started := time.Now()
+
+		data, peeked, err := peekRequestBody(req, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		req = peeked
+		reqBody := capturedBody(data, limit)
+
+		resp, err := next.Do(req)
+		wait := time.Since(started)
+
+		var respBody harBody
+
+		if resp != nil && resp.Body != nil && resp.Body != http.NoBody {
+			data, resp.Body = peekBody(resp.Body, limit)
+			respBody = capturedBody(data, limit)
+		}
+
+		entry := newHAREntry(h.rd, req, reqBody, resp, respBody, started, wait, time.Since(started)-wait)
+		if err != nil {
+			entry.Comment = errorComment(h.rd, req, err)
+		}
+
+		h.mu.Lock()
+		h.entries = append(h.entries, *entry)
+		h.mu.Unlock()
+
+		return resp, err
+	})
+}
+
+// HAR returns the recorded exchanges
+func (h *HARRecorder) HAR() *HAR {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := make([]HAREntry, len(h.entries))
+	copy(entries, h.entries)
+
+	return &HAR{Log: HARLog{
+		Version: HARVersion,
+		Creator: HARCreator{Name: "httpsling"},
+		Entries: entries,
+		Comment: h.c.Comment,
+	}}
+}
+
+// Save writes the recorded exchanges to a HAR file
+func (h *HARRecorder) Save(path string) error {
+	return h.HAR().Save(path)
+}
+
+// Reset discards the recorded exchanges
+func (h *HARRecorder) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = nil
+}
@@ -0,0 +1,107 @@
+package httpsling_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+func TestHARRecorder(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(201,
+		httpsling.Body([]byte{0x00, 0x01, 0xff}),
+		httpsling.ContentType("application/octet-stream"),
+		httpsling.Header(httpsling.HeaderSetCookie, "session=abc")))
+	defer s.Close()
+
+	rec := httpsling.NewHARRecorder(&httpsling.RecordConfig{
+		Redactor: &httpsling.Redactor{Headers: httpsling.DefaultRedactedHeaders, QueryParams: []string{"key"}},
+		Comment:  "recorded in a test",
+	})
+
+	r := httptestutil.Requester(s, rec)
+
+	resp, err := r.Send(httpsling.Post("/things"), httpsling.QueryParam("key", "secret"),
+		httpsling.QueryParam("q", "1"), httpsling.BearerAuth("token"), httpsling.Body(map[string]string{"name": "cat"}))
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []byte{0x00, 0x01, 0xff}, body)
+
+	har := rec.HAR()
+	assert.Equal(t, httpsling.HARVersion, har.Log.Version)
+	assert.Equal(t, "httpsling", har.Log.Creator.Name)
+	assert.Equal(t, "recorded in a test", har.Log.Comment)
+	require.Len(t, har.Log.Entries, 1)
+
+	entry := har.Log.Entries[0]
+	assert.Equal(t, http.MethodPost, entry.Request.Method)
+	assert.Equal(t, s.URL+"/things?key=[REDACTED]&q=1", entry.Request.URL)
+	assert.Equal(t, []httpsling.HARNameValue{{Name: "key", Value: httpsling.Redacted}, {Name: "q", Value: "1"}}, entry.Request.QueryString)
+	assert.Contains(t, entry.Request.Headers, httpsling.HARNameValue{Name: httpsling.HeaderAuthorization, Value: httpsling.Redacted})
+	require.NotNil(t, entry.Request.PostData)
+	assert.JSONEq(t, `{"name":"cat"}`, entry.Request.PostData.Text)
+
+	assert.Equal(t, 201, entry.Response.Status)
+	assert.Equal(t, []httpsling.HARCookie{{Name: "session", Value: httpsling.Redacted}}, entry.Response.Cookies)
+	assert.Equal(t, "base64", entry.Response.Content.Encoding)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte{0x00, 0x01, 0xff}), entry.Response.Content.Text)
+	assert.GreaterOrEqual(t, entry.Timings.Wait, 0.0)
+	assert.GreaterOrEqual(t, entry.Timings.Receive, 0.0)
+	assert.InDelta(t, entry.Timings.Wait+entry.Timings.Receive, entry.Time, 0.001)
+
+	path := filepath.Join(t.TempDir(), "recording.har")
+	require.NoError(t, rec.Save(path))
+
+	loaded, err := httpsling.LoadHAR(path)
+	require.NoError(t, err)
+	assert.Equal(t, har.Log.Entries[0].Request, loaded.Log.Entries[0].Request)
+	assert.Equal(t, har.Log.Entries[0].Response, loaded.Log.Entries[0].Response)
+
+	rec.Reset()
+	assert.Empty(t, rec.HAR().Log.Entries)
+}
+
+func TestHARRecorderRedactsErrors(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(200))
+	url := s.URL
+	s.Close()
+
+	rec := httpsling.NewHARRecorder(&httpsling.RecordConfig{Redactor: &httpsling.Redactor{QueryParams: []string{"key"}}})
+
+	_, err := httpsling.MustNew(rec).Receive(nil, httpsling.Get(url+"/things?key=secret"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "secret")
+
+	entries := rec.HAR().Log.Entries
+	require.Len(t, entries, 1)
+	assert.Contains(t, entries[0].Comment, "key=[REDACTED]")
+	assert.NotContains(t, entries[0].Comment, "secret")
+}
+
+func TestHARRecorderError(t *testing.T) {
+	rec := httpsling.NewHARRecorder(nil)
+
+	r := httpsling.MustNew(httpsling.WithDoer(httpsling.DoerFunc(func(_ *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})), rec)
+
+	_, err := r.Receive(nil, httpsling.Get("http://example.com/"))
+	require.Error(t, err)
+
+	entries := rec.HAR().Log.Entries
+	require.Len(t, entries, 1)
+	assert.Equal(t, 0, entries[0].Response.Status)
+	assert.Contains(t, entries[0].Comment, "connection refused")
+}
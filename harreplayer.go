@@ -0,0 +1,364 @@
+package httpsling
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HARMatcher reports whether a request, whose body has been read, matches a recorded HAR entry
+type HARMatcher func(req *http.Request, body []byte, entry *HAREntry) bool
+
+// MatchMethod matches requests with the method of the entry
+func MatchMethod(req *http.Request, _ []byte, entry *HAREntry) bool {
+	return req.Method == entry.Request.Method
+}
+
+// MatchURL matches requests with the scheme, host and path of the entry, ignoring the query and credentials
+func MatchURL(req *http.Request, _ []byte, entry *HAREntry) bool {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return false
+	}
+
+	reqURL := requestURL(req)
+
+	return strings.EqualFold(reqURL.Scheme, u.Scheme) && strings.EqualFold(reqURL.Host, u.Host) &&
+		reqURL.EscapedPath() == u.EscapedPath()
+}
+
+// MatchQuery matches requests with the query parameters of the entry, regardless of their order; recorded values
+// which were redacted match any value
+func MatchQuery(req *http.Request, _ []byte, entry *HAREntry) bool {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return false
+	}
+
+	return valuesMatch(u.Query(), req.URL.Query())
+}
+
+// valuesMatch compares query parameters or form fields regardless of their order; recorded values which were
+// redacted match any value
+func valuesMatch(recorded, actual url.Values) bool {
+	if len(recorded) != len(actual) {
+		return false
+	}
+
+	for key, values := range recorded {
+		got := slices.Clone(actual[key])
+		if len(got) != len(values) {
+			return false
+		}
+
+		values = slices.Clone(values)
+		slices.Sort(values)
+		slices.Sort(got)
+
+		for i, v := range values {
+			if v != got[i] && v != Redacted {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// MatchBody matches requests with the body of the entry. JSON bodies are compared by value, so formatting and the
+// order of object fields don't matter, and form bodies regardless of the order of their fields; like MatchQuery,
+// recorded JSON values and form fields which were redacted match any value
+func MatchBody(req *http.Request, body []byte, entry *HAREntry) bool {
+	var recorded []byte
+
+	if entry.Request.PostData != nil {
+		var err error
+
+		recorded, err = harText(entry.Request.PostData.Text, entry.Request.PostData.Encoding)
+		if err != nil {
+			return false
+		}
+	}
+
+	if bytes.Equal(body, recorded) {
+		return true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get(HeaderContentType))
+	if mediaType == ContentTypeForm {
+		recordedForm, err := url.ParseQuery(string(recorded))
+		if err != nil {
+			return false
+		}
+
+		form, err := url.ParseQuery(string(body))
+
+		return err == nil && valuesMatch(recordedForm, form)
+	}
+
+	var actualValue, recordedValue any
+
+	return json.Unmarshal(body, &actualValue) == nil && json.Unmarshal(recorded, &recordedValue) == nil &&
+		jsonMatches(recordedValue, actualValue)
+}
+
+// jsonMatches compares decoded JSON values; recorded values which were redacted match any value
+func jsonMatches(recorded, actual any) bool {
+	switch r := recorded.(type) {
+	case string:
+		return r == Redacted || r == actual
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok || len(a) != len(r) {
+			return false
+		}
+
+		for key, v := range r {
+			av, ok := a[key]
+			if !ok || !jsonMatches(v, av) {
+				return false
+			}
+		}
+
+		return true
+	case []any:
+		a, ok := actual.([]any)
+		if !ok || len(a) != len(r) {
+			return false
+		}
+
+		for i := range r {
+			if !jsonMatches(r[i], a[i]) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return reflect.DeepEqual(recorded, actual)
+	}
+}
+
+// MatchHeaders returns a HARMatcher matching requests with the values of the named headers of the entry
+func MatchHeaders(names ...string) HARMatcher {
+	return func(req *http.Request, _ []byte, entry *HAREntry) bool {
+		recorded := http.Header{}
+		for _, h := range entry.Request.Headers {
+			recorded.Add(h.Name, h.Value)
+		}
+
+		for _, name := range names {
+			if !slices.Equal(req.Header.Values(name), recorded.Values(name)) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// DefaultHARMatchers match requests on their method, URL, query and body
+var DefaultHARMatchers = []HARMatcher{MatchMethod, MatchURL, MatchQuery, MatchBody}
+
+// ReplayConfig defines settings for a HARReplayer
+type ReplayConfig struct {
+	// Matchers must all match a request to replay an entry (default DefaultHARMatchers)
+	Matchers []HARMatcher
+	// Once fails requests whose matching entries have all been replayed, instead of replaying the last of them again
+	Once bool
+}
+
+func (c *ReplayConfig) normalize() {
+	if len(c.Matchers) == 0 {
+		c.Matchers = DefaultHARMatchers
+	}
+}
+
+// HARMismatchError is returned by a HARReplayer when no recorded entry matches a request
+type HARMismatchError struct {
+	// Method is the method of the request
+	Method string
+	// URL is the URL of the request
+	URL string
+	// Closest is the index of the entry which matched the most matchers, or -1 if there are no entries
+	Closest int
+	// Replayed is true if entries matched the request but have all been replayed
+	Replayed bool
+
+	closest string
+}
+
+// Error implements error
+func (e *HARMismatchError) Error() string {
+	switch {
+	case e.Replayed:
+		return fmt.Sprintf("%s: %s %s: matching entries have all been replayed", ErrNoHAREntry, e.Method, e.URL)
+	case e.Closest < 0:
+		return fmt.Sprintf("%s: %s %s: the HAR has no entries", ErrNoHAREntry, e.Method, e.URL)
+	default:
+		return fmt.Sprintf("%s: %s %s: closest is entry %d, %s", ErrNoHAREntry, e.Method, e.URL, e.Closest,
+			e.closest)
+	}
+}
+
+// Unwrap returns ErrNoHAREntry
+func (e *HARMismatchError) Unwrap() error {
+	return ErrNoHAREntry
+}
+
+// HARReplayer is a Doer which replays the responses of recorded HAR entries, for deterministic tests against
+// traffic captured with a HARRecorder. Each request is answered with the first entry matched by all the matchers
+// which hasn't been replayed yet; entries recorded with a transport error replay the error
+type HARReplayer struct {
+	c        ReplayConfig
+	entries  []HAREntry
+	mu       sync.Mutex
+	replayed []bool
+}
+
+// NewHARReplayer returns a HARReplayer replaying the entries of the HAR
+func NewHARReplayer(har *HAR, config *ReplayConfig) *HARReplayer {
+	h := &HARReplayer{}
+	if config != nil {
+		h.c = *config
+	}
+
+	h.c.normalize()
+
+	if har != nil {
+		h.entries = har.Log.Entries
+	}
+
+	h.replayed = make([]bool, len(h.entries))
+
+	return h
+}
+
+// Do implements Doer
+func (h *HARReplayer) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+
+	if req.Body != nil {
+		var err error
+
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entry, err := h.match(req, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.Response.Status == 0 && entry.Comment != "" {
+		return nil, fmt.Errorf("%w: %s", ErrRecordedFailure, entry.Comment)
+	}
+
+	return replayResponse(req, entry)
+}
+
+// match finds the entry to replay and marks it as replayed
+func (h *HARReplayer) match(req *http.Request, body []byte) (*HAREntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	last, closest, most := -1, -1, -1
+
+	for i := range h.entries {
+		matched := 0
+
+		for _, m := range h.c.Matchers {
+			if m(req, body, &h.entries[i]) {
+				matched++
+			}
+		}
+
+		if matched == len(h.c.Matchers) {
+			if !h.replayed[i] {
+				h.replayed[i] = true
+
+				return &h.entries[i], nil
+			}
+
+			last = i
+		}
+
+		if matched > most {
+			closest, most = i, matched
+		}
+	}
+
+	if last >= 0 && !h.c.Once {
+		return &h.entries[last], nil
+	}
+
+	err := &HARMismatchError{Method: req.Method, URL: req.URL.String(), Closest: closest, Replayed: last >= 0}
+	if closest >= 0 {
+		err.closest = h.entries[closest].Request.Method + " " + h.entries[closest].Request.URL
+	}
+
+	return nil, err
+}
+
+// Unreplayed returns the indexes of the entries which haven't been replayed, to check a test sent every recorded
+// request
+func (h *HARReplayer) Unreplayed() []int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var unreplayed []int
+
+	for i, replayed := range h.replayed {
+		if !replayed {
+			unreplayed = append(unreplayed, i)
+		}
+	}
+
+	return unreplayed
+}
+
+// replayResponse builds the response of the entry
+func replayResponse(req *http.Request, entry *HAREntry) (*http.Response, error) {
+	body, err := harText(entry.Response.Content.Text, entry.Response.Content.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("decoding the recorded body: %w", err)
+	}
+
+	proto := httpVersion(entry.Response.HTTPVersion)
+
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		proto, major, minor = "HTTP/1.1", 1, 1
+	}
+
+	resp := &http.Response{
+		Status:        strconv.Itoa(entry.Response.Status) + " " + http.StatusText(entry.Response.Status),
+		StatusCode:    entry.Response.Status,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        http.Header{},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+
+	for _, h := range entry.Response.Headers {
+		resp.Header.Add(h.Name, h.Value)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,220 @@
+package httpsling_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+// record sends the requests to a server echoing the request URL and body, and returns the recording
+func record(t *testing.T, opts ...[]httpsling.Option) *httpsling.HAR {
+	t.Helper()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httpsling.HeaderContentType, httpsling.ContentTypeText)
+		w.Header().Set("X-Method", r.Method)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(r.URL.RequestURI()))
+	}))
+	defer s.Close()
+
+	rec := httpsling.NewHARRecorder(nil)
+	r := httptestutil.Requester(s, rec)
+
+	for _, o := range opts {
+		resp, err := r.Send(o...)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	return rec.HAR()
+}
+
+func TestHARReplayer(t *testing.T) {
+	har := record(t,
+		[]httpsling.Option{httpsling.Get("/things"), httpsling.QueryParam("a", "1"), httpsling.QueryParam("b", "2")},
+		[]httpsling.Option{httpsling.Post("/things"), httpsling.Body(map[string]any{"name": "cat", "age": 3})},
+		[]httpsling.Option{httpsling.Post("/things"), httpsling.Body(map[string]any{"name": "dog", "age": 5})},
+	)
+
+	replayer := httpsling.NewHARReplayer(har, nil)
+	r := httpsling.MustNew(httpsling.WithDoer(replayer), httpsling.URL("http://"+requestHost(har)))
+
+	tests := []struct {
+		name string
+		opts []httpsling.Option
+		body string
+	}{
+		{
+			name: "query in another order",
+			opts: []httpsling.Option{httpsling.Get("/things?b=2&a=1")},
+			body: "/things?a=1&b=2",
+		},
+		{
+			name: "json fields in another order",
+			opts: []httpsling.Option{httpsling.Post("/things"), httpsling.Body(`{"age":5,"name":"dog"}`)},
+			body: "/things",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var body string
+
+			resp, err := r.Receive(&body, tc.opts...)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+			assert.Equal(t, tc.body, body)
+			assert.Equal(t, httpsling.ContentTypeText, resp.Header.Get(httpsling.HeaderContentType))
+		})
+	}
+
+	assert.Equal(t, []int{1}, replayer.Unreplayed())
+
+	// entries which have been replayed are replayed again
+	resp, err := r.Receive(nil, httpsling.Get("/things?a=1&b=2"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
+
+func TestHARReplayerMismatch(t *testing.T) {
+	har := record(t,
+		[]httpsling.Option{httpsling.Get("/things")},
+		[]httpsling.Option{httpsling.Post("/things"), httpsling.Body("a")},
+	)
+
+	host := "http://" + requestHost(har)
+
+	tests := []struct {
+		name     string
+		config   *httpsling.ReplayConfig
+		opts     []httpsling.Option
+		closest  int
+		replayed bool
+	}{
+		{name: "method", opts: []httpsling.Option{httpsling.Delete("/things")}, closest: 0},
+		{name: "body", opts: []httpsling.Option{httpsling.Post("/things"), httpsling.Body("b")}, closest: 1},
+		{name: "query", opts: []httpsling.Option{httpsling.Get("/things?x=1")}, closest: 0},
+		{name: "path", opts: []httpsling.Option{httpsling.Get("/other")}, closest: 0},
+		{
+			name:     "replayed once",
+			config:   &httpsling.ReplayConfig{Once: true},
+			opts:     []httpsling.Option{httpsling.Get("/things")},
+			closest:  0,
+			replayed: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			replayer := httpsling.NewHARReplayer(har, tc.config)
+			r := httpsling.MustNew(httpsling.WithDoer(replayer), httpsling.URL(host))
+
+			if tc.replayed {
+				_, err := r.Receive(nil, tc.opts...)
+				require.NoError(t, err)
+			}
+
+			_, err := r.Receive(nil, tc.opts...)
+			require.ErrorIs(t, err, httpsling.ErrNoHAREntry)
+
+			var mismatch *httpsling.HARMismatchError
+			require.ErrorAs(t, err, &mismatch)
+			assert.Equal(t, tc.closest, mismatch.Closest)
+			assert.Equal(t, tc.replayed, mismatch.Replayed)
+		})
+	}
+}
+
+func TestHARReplayerMatchers(t *testing.T) {
+	har := record(t, []httpsling.Option{httpsling.Get("/things"), httpsling.QueryParam("t", "1"), httpsling.Header("X-Tenant", "a")})
+	host := "http://" + requestHost(har)
+
+	replayer := httpsling.NewHARReplayer(har, &httpsling.ReplayConfig{
+		Matchers: []httpsling.HARMatcher{httpsling.MatchMethod, httpsling.MatchURL, httpsling.MatchHeaders("X-Tenant")},
+	})
+	r := httpsling.MustNew(httpsling.WithDoer(replayer), httpsling.URL(host))
+
+	_, err := r.Receive(nil, httpsling.Get("/things?t=2"), httpsling.Header("X-Tenant", "a"))
+	require.NoError(t, err)
+
+	_, err = r.Receive(nil, httpsling.Get("/things?t=1"), httpsling.Header("X-Tenant", "b"))
+	require.ErrorIs(t, err, httpsling.ErrNoHAREntry)
+}
+
+func TestHARReplayerRedactedQuery(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(200, httpsling.Body("ok")))
+	defer s.Close()
+
+	rec := httpsling.NewHARRecorder(&httpsling.RecordConfig{Redactor: &httpsling.Redactor{QueryParams: []string{"key"}}})
+
+	_, err := httptestutil.Requester(s, rec).Receive(nil, httpsling.Get("/things?key=secret"))
+	require.NoError(t, err)
+
+	r := httpsling.MustNew(httpsling.WithDoer(httpsling.NewHARReplayer(rec.HAR(), nil)), httpsling.URL(s.URL))
+
+	var body string
+
+	_, err = r.Receive(&body, httpsling.Get("/things?key=other"))
+	require.NoError(t, err)
+	assert.Equal(t, "ok", body)
+}
+
+func TestHARReplayerRedactedBody(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(200, httpsling.Body("ok")))
+	defer s.Close()
+
+	rec := httpsling.NewHARRecorder(&httpsling.RecordConfig{Redactor: &httpsling.Redactor{BodyFields: []string{"password"}}})
+	r := httptestutil.Requester(s, rec)
+
+	_, err := r.Receive(nil, httpsling.Post("/login"), httpsling.Body(map[string]any{"user": "cat", "password": "secret"}))
+	require.NoError(t, err)
+
+	_, err = r.Receive(nil, httpsling.Post("/form"), httpsling.Form(),
+		httpsling.Body(map[string][]string{"user": {"cat"}, "password": {"secret"}}))
+	require.NoError(t, err)
+
+	replayer := httpsling.NewHARReplayer(rec.HAR(), &httpsling.ReplayConfig{Once: true})
+	r = httpsling.MustNew(httpsling.WithDoer(replayer), httpsling.URL(s.URL))
+
+	_, err = r.Receive(nil, httpsling.Post("/login"), httpsling.Body(map[string]any{"user": "dog", "password": "other"}))
+	require.ErrorIs(t, err, httpsling.ErrNoHAREntry)
+
+	_, err = r.Receive(nil, httpsling.Post("/login"), httpsling.Body(map[string]any{"user": "cat", "password": "other"}))
+	require.NoError(t, err)
+
+	_, err = r.Receive(nil, httpsling.Post("/form"), httpsling.Form(),
+		httpsling.Body(map[string][]string{"password": {"other"}, "user": {"cat"}}))
+	require.NoError(t, err)
+
+	assert.Empty(t, replayer.Unreplayed())
+}
+
+func TestHARReplayerRecordedFailure(t *testing.T) {
+	rec := httpsling.NewHARRecorder(nil)
+
+	_, err := httpsling.MustNew(httpsling.WithDoer(httpsling.DoerFunc(func(_ *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})), rec).Receive(nil, httpsling.Get("http://example.com/"))
+	require.Error(t, err)
+
+	_, err = httpsling.MustNew(httpsling.WithDoer(httpsling.NewHARReplayer(rec.HAR(), nil))).
+		Receive(nil, httpsling.Get("http://example.com/"))
+	require.ErrorIs(t, err, httpsling.ErrRecordedFailure)
+	assert.Contains(t, err.Error(), "connection refused")
+}
+
+// requestHost returns the host of the first recorded request
+func requestHost(har *httpsling.HAR) string {
+	u, _ := url.Parse(har.Log.Entries[0].Request.URL)
+
+	return u.Host
+}
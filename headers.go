@@ -17,6 +17,7 @@ const (
 	HeaderExpires       = "Expires"
 	HeaderPragma        = "Pragma"
 	HeaderWarning       = "Warning"
+	HeaderXCache        = "X-Cache"
 
 	// Client hints
 	HeaderAcceptCH         = "Accept-CH"
@@ -75,17 +76,20 @@ const (
 	HeaderContentDisposition = "Content-Disposition"
 
 	// Message body information
+	HeaderContentDigest   = "Content-Digest"
 	HeaderContentEncoding = "Content-Encoding"
 	HeaderContentLanguage = "Content-Language"
 	HeaderContentLength   = "Content-Length"
 	HeaderContentLocation = "Content-Location"
 	HeaderContentType     = "Content-Type"
+	HeaderReprDigest      = "Repr-Digest"
 
 	// Content Types
 	ContentTypeForm                   = "application/x-www-form-urlencoded" // https://datatracker.ietf.org/doc/html/rfc1866
 	ContentTypeMultipart              = "multipart/form-data"               // https://datatracker.ietf.org/doc/html/rfc2388
 	ContentTypeJSON                   = "application/json"                  // https://datatracker.ietf.org/doc/html/rfc4627
 	ContentTypeJSONUTF8               = "application/json;charset=utf-8"    // https://datatracker.ietf.org/doc/html/rfc4627
+	ContentTypeProblemJSON            = "application/problem+json"          // https://www.rfc-editor.org/rfc/rfc9457.html
 	ContentTypeXML                    = "application/xml"                   // https://datatracker.ietf.org/doc/html/rfc3023
 	ContentTypeXMLUTF8                = "application/xml;charset=utf-8"
 	ContentTypeYAML                   = "application/yaml" // https://www.rfc-editor.org/rfc/rfc9512.html
@@ -94,6 +98,12 @@ const (
 	ContentTypeTextUTF8               = "text/plain;charset=utf-8"
 	ContentTypeApplicationOctetStream = "application/octet-stream"
 
+	// Content encodings
+	EncodingBrotli  = "br"
+	EncodingDeflate = "deflate"
+	EncodingGzip    = "gzip"
+	EncodingZstd    = "zstd"
+
 	// Proxies
 	HeaderForwarded       = "Forwarded"
 	HeaderVia             = "Via"
@@ -101,6 +111,16 @@ const (
 	HeaderXForwardedHost  = "X-Forwarded-Host"
 	HeaderXForwardedProto = "X-Forwarded-Proto"
 
+	// Rate limiting
+	HeaderRateLimit           = "RateLimit"
+	HeaderRateLimitLimit      = "RateLimit-Limit"
+	HeaderRateLimitPolicy     = "RateLimit-Policy"
+	HeaderRateLimitRemaining  = "RateLimit-Remaining"
+	HeaderRateLimitReset      = "RateLimit-Reset"
+	HeaderXRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderXRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderXRateLimitReset     = "X-RateLimit-Reset"
+
 	// Redirects
 	HeaderLocation = "Location"
 
@@ -169,6 +189,7 @@ const (
 	HeaderRetryAfter          = "Retry-After"
 	HeaderServerTiming        = "Server-Timing"
 	HeaderSignature           = "Signature"
+	HeaderSignatureInput      = "Signature-Input"
 	HeaderSignedHeaders       = "Signed-Headers"
 	HeaderSourceMap           = "SourceMap"
 	HeaderUpgrade             = "Upgrade"
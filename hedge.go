@@ -0,0 +1,260 @@
+package httpsling
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// hedgeWindow is the number of recent latencies the hedging delay percentile is computed from
+	hedgeWindow = 100
+	// hedgeMinSamples is the number of latencies needed before the percentile is used instead of Delay
+	hedgeMinSamples = 10
+)
+
+// HedgeConfig defines settings for the Hedge middleware
+type HedgeConfig struct {
+	// Delay is how long to wait for a response before sending a hedged request (default 100ms)
+	Delay time.Duration
+	// Percentile, if set, derives the delay from the given percentile (e.g. 0.95) of the latencies of recent
+	// successful requests; Delay is used until enough requests have been observed
+	Percentile float64
+	// MaxHedges is the number of hedged requests sent in addition to the original request (default 1)
+	MaxHedges int
+	// ShouldHedge tests whether a request may be hedged (default OnlyIdempotentShouldRetry); it is called with
+	// a nil response and error
+	ShouldHedge ShouldRetryer
+	// IsFailure tests whether a response or error is a failure (default DefaultShouldRetry); failed attempts
+	// don't win the race, and trigger the next hedged request immediately
+	IsFailure ShouldRetryer
+}
+
+func (c *HedgeConfig) normalize() {
+	if c.Delay <= 0 {
+		c.Delay = 100 * time.Millisecond // nolint: mnd
+	}
+
+	if c.MaxHedges < 1 {
+		c.MaxHedges = 1
+	}
+
+	if c.ShouldHedge == nil {
+		c.ShouldHedge = ShouldRetryerFunc(OnlyIdempotentShouldRetry)
+	}
+
+	if c.IsFailure == nil {
+		c.IsFailure = ShouldRetryerFunc(DefaultShouldRetry)
+	}
+}
+
+// Hedge sends a duplicate of a slow request after a delay, and returns the first successful response; the
+// other requests are canceled and their responses drained. Only requests accepted by ShouldHedge are hedged,
+// and, like with Retry, requests with a body are only hedged if the body can be replayed with GetBody.
+// If every attempt fails, the first failure is returned
+func Hedge(config *HedgeConfig) Middleware {
+	c := HedgeConfig{}
+	if config != nil {
+		c = *config
+	}
+
+	c.normalize()
+
+	latencies := &latencyWindow{}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if bodyEmpty(req) || !c.ShouldHedge.ShouldRetry(0, req, nil, nil) {
+				return next.Do(req)
+			}
+
+			h := &hedger{
+				next:    next,
+				ctx:     req.Context(),
+				results: make(chan hedgeResult, c.MaxHedges+1),
+			}
+
+			return h.do(req, &c, latencies)
+		})
+	}
+}
+
+// hedgeResult is the outcome of one of the attempts of a hedged request
+type hedgeResult struct {
+	attempt  int
+	resp     *http.Response
+	err      error
+	duration time.Duration
+}
+
+// hedger runs the attempts of a single hedged request
+type hedger struct {
+	next    Doer
+	ctx     context.Context
+	results chan hedgeResult
+	cancels []context.CancelFunc
+}
+
+// send starts an attempt with its own cancelable context
+func (h *hedger) send(req *http.Request) {
+	ctx, cancel := context.WithCancel(h.ctx)
+	attempt := len(h.cancels)
+
+	h.cancels = append(h.cancels, cancel)
+
+	go func() {
+		start := time.Now()
+		resp, err := h.next.Do(req.WithContext(ctx))
+
+		h.results <- hedgeResult{attempt: attempt, resp: resp, err: err, duration: time.Since(start)}
+	}()
+}
+
+func (h *hedger) do(req *http.Request, c *HedgeConfig, latencies *latencyWindow) (*http.Response, error) {
+	h.send(req)
+
+	sent, pending := 1, 1
+
+	timer := time.NewTimer(latencies.delay(c))
+	defer timer.Stop()
+
+	var failed *hedgeResult
+
+	for pending > 0 {
+		hedge := false
+
+		select {
+		case res := <-h.results:
+			pending--
+
+			if res.err == nil && !c.IsFailure.ShouldRetry(0, req, res.resp, res.err) {
+				latencies.add(res.duration)
+				h.discard(res.attempt, failed, pending)
+
+				return h.keep(res)
+			}
+
+			if failed == nil {
+				failed = &res
+			} else {
+				h.cancels[res.attempt]()
+				drainResponse(res.resp)
+			}
+
+			hedge = h.ctx.Err() == nil
+		case <-timer.C:
+			hedge = true
+		}
+
+		if !hedge || sent > c.MaxHedges {
+			continue
+		}
+
+		hedgeReq, err := resetRequest(req)
+		if err != nil {
+			// without a replayable body no further attempts are made
+			sent = c.MaxHedges + 1
+
+			continue
+		}
+
+		h.send(hedgeReq)
+
+		sent++
+		pending++
+
+		timer.Reset(latencies.delay(c))
+	}
+
+	return h.keep(*failed)
+}
+
+// keep returns the response of an attempt, canceling the attempt's context only once the body is closed
+func (h *hedger) keep(res hedgeResult) (*http.Response, error) {
+	cancel := h.cancels[res.attempt]
+
+	if res.resp == nil || res.resp.Body == nil {
+		cancel()
+
+		return res.resp, res.err
+	}
+
+	res.resp.Body = &releaseOnClose{ReadCloser: res.resp.Body, release: cancel}
+
+	return res.resp, res.err
+}
+
+// discard cancels the attempts which lost the race to the winner, and drains their responses in the background
+func (h *hedger) discard(winner int, failed *hedgeResult, pending int) {
+	for attempt, cancel := range h.cancels {
+		if attempt != winner {
+			cancel()
+		}
+	}
+
+	if failed != nil {
+		drainResponse(failed.resp)
+	}
+
+	if pending == 0 {
+		return
+	}
+
+	go func() {
+		for range pending {
+			res := <-h.results
+			drainResponse(res.resp)
+		}
+	}()
+}
+
+// drainResponse drains and closes the body of resp, which may be nil
+func drainResponse(resp *http.Response) {
+	if resp != nil {
+		drain(resp.Body)
+	}
+}
+
+// latencyWindow holds the latencies of recent requests
+type latencyWindow struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.latencies) < hedgeWindow {
+		w.latencies = append(w.latencies, d)
+
+		return
+	}
+
+	w.latencies[w.next] = d
+	w.next = (w.next + 1) % hedgeWindow
+}
+
+// delay returns how long to wait before sending the next hedged request
+func (w *latencyWindow) delay(c *HedgeConfig) time.Duration {
+	if c.Percentile <= 0 {
+		return c.Delay
+	}
+
+	w.mu.Lock()
+	sorted := slices.Clone(w.latencies)
+	w.mu.Unlock()
+
+	if len(sorted) < hedgeMinSamples {
+		return c.Delay
+	}
+
+	slices.Sort(sorted)
+
+	i := int(c.Percentile * float64(len(sorted)-1))
+
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
@@ -0,0 +1,183 @@
+package httpsling_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+// slowFirstServer stalls the first request until it is canceled, and answers the others immediately
+func slowFirstServer(t *testing.T, calls *atomic.Int32, canceled chan<- struct{}) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+				canceled <- struct{}{}
+			case <-time.After(5 * time.Second):
+			}
+
+			return
+		}
+
+		w.Write(append([]byte("fast"), body...)) // nolint: errcheck
+	}))
+}
+
+func TestHedge(t *testing.T) {
+	var calls atomic.Int32
+
+	canceled := make(chan struct{}, 1)
+
+	s := slowFirstServer(t, &calls, canceled)
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Hedge(&httpsling.HedgeConfig{Delay: 20 * time.Millisecond}))
+
+	t0 := time.Now()
+
+	var out string
+
+	resp, err := r.Receive(&out)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, "fast", out)
+	assert.Less(t, time.Since(t0), time.Second)
+	assert.EqualValues(t, 2, calls.Load())
+
+	// the losing request is canceled
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("slow request was not canceled")
+	}
+}
+
+func TestHedgeBody(t *testing.T) {
+	var calls atomic.Int32
+
+	s := slowFirstServer(t, &calls, make(chan struct{}, 1))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Hedge(&httpsling.HedgeConfig{
+		Delay: 10 * time.Millisecond,
+		ShouldHedge: httpsling.ShouldRetryerFunc(func(int, *http.Request, *http.Response, error) bool {
+			return true
+		}),
+	}))
+
+	var out string
+
+	// the body is replayed for the hedged request
+	resp, err := r.Receive(&out, httpsling.Post(), httpsling.Body("-body"))
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, "fast-body", out)
+}
+
+func TestHedgeNotIdempotent(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer s.Close()
+
+	i := httptestutil.Inspect(s)
+	r := httptestutil.Requester(s, httpsling.Hedge(&httpsling.HedgeConfig{Delay: time.Millisecond}))
+
+	resp, err := r.Receive(nil, httpsling.Post())
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Len(t, i.Drain(), 1)
+}
+
+func TestHedgeFailures(t *testing.T) {
+	var calls atomic.Int32
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if n := calls.Add(1); n == 1 || n == 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer s.Close()
+
+	// a failed attempt triggers the hedged request without waiting for the delay
+	r := httptestutil.Requester(s, httpsling.Hedge(&httpsling.HedgeConfig{Delay: time.Hour}))
+
+	resp, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 2, calls.Load())
+
+	// when every attempt fails the first failure is returned
+	failing := httptest.NewServer(httpsling.MockHandler(http.StatusBadGateway))
+	defer failing.Close()
+
+	i := httptestutil.Inspect(failing)
+	r = httptestutil.Requester(failing, httpsling.Hedge(&httpsling.HedgeConfig{Delay: time.Hour, MaxHedges: 2}))
+
+	resp, err = r.Receive(nil)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Len(t, i.Drain(), 3)
+}
+
+func TestHedgePercentile(t *testing.T) {
+	var slow atomic.Bool
+
+	canceled := make(chan struct{}, 1)
+
+	s := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if slow.CompareAndSwap(true, false) {
+			<-r.Context().Done()
+			canceled <- struct{}{}
+		}
+	}))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Hedge(&httpsling.HedgeConfig{Delay: time.Hour, Percentile: 0.9}))
+
+	for range 10 {
+		resp, err := r.Receive(nil)
+		require.NoError(t, err)
+
+		resp.Body.Close()
+	}
+
+	// the delay is now derived from the fast requests observed so far
+	slow.Store(true)
+
+	t0 := time.Now()
+
+	resp, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Less(t, time.Since(t0), time.Second)
+	<-canceled
+}
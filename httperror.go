@@ -0,0 +1,169 @@
+package httpsling
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// MaxErrorBodySize is the maximum number of bytes of the response body copied into an HTTPError
+var MaxErrorBodySize int64 = 64 << 10
+
+// HTTPError is returned when a response has an unexpected status code; it can be retrieved with errors.As
+// and matches ErrUnsuccessfulResponse with errors.Is. If the response was a problem document, the decoded
+// *Problem can also be retrieved with errors.As
+type HTTPError struct {
+	// Method is the method of the request
+	Method string
+	// URL is the URL of the request
+	URL string
+	// StatusCode is the status code of the response
+	StatusCode int
+	// ExpectedCode is the status code which was expected, or 0 if any successful status code was expected
+	ExpectedCode int
+	// Header contains the response headers
+	Header http.Header
+	// Body contains up to MaxErrorBodySize bytes of the response body
+	Body []byte
+	// Problem is the decoded response body, if the response was an application/problem+json document
+	Problem *Problem
+
+	// cause is the error decoding the response body, when Receive failed to decode it
+	cause error
+}
+
+// Error implements error
+func (e *HTTPError) Error() string {
+	var sb strings.Builder
+
+	if e.Method != "" || e.URL != "" {
+		sb.WriteString(strings.TrimSpace(e.Method + " " + e.URL))
+		sb.WriteString(": ")
+	}
+
+	sb.WriteString(ErrUnsuccessfulResponse.Error())
+
+	if e.ExpectedCode > 0 {
+		fmt.Fprintf(&sb, ": server returned unexpected status code. expected: %d, received: %d", e.ExpectedCode, e.StatusCode)
+	} else {
+		fmt.Fprintf(&sb, ": server returned unsuccessful status code: %d", e.StatusCode)
+	}
+
+	if e.Problem != nil {
+		for _, s := range []string{e.Problem.Title, e.Problem.Detail} {
+			if s != "" {
+				sb.WriteString(": " + s)
+			}
+		}
+	}
+
+	if e.cause != nil {
+		sb.WriteString(": " + e.cause.Error())
+	}
+
+	return sb.String()
+}
+
+// Unwrap returns ErrUnsuccessfulResponse, the Problem if one was decoded, and the error decoding the body if
+// Receive failed to decode it
+func (e *HTTPError) Unwrap() []error {
+	errs := []error{ErrUnsuccessfulResponse}
+
+	if e.Problem != nil {
+		errs = append(errs, e.Problem)
+	}
+
+	if e.cause != nil {
+		errs = append(errs, e.cause)
+	}
+
+	return errs
+}
+
+// NewHTTPError builds an HTTPError from the response; up to MaxErrorBodySize bytes of the body are copied into
+// the error, and the response body is replaced so it can still be read in full by the caller
+func NewHTTPError(resp *http.Response, expectedCode int) *HTTPError {
+	e := newHTTPError(resp, expectedCode)
+
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return e
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxErrorBodySize))
+	resp.Body = &prefixedReadCloser{
+		Reader: io.MultiReader(bytes.NewReader(body), &errReader{err: err}, resp.Body),
+		Closer: resp.Body,
+	}
+
+	e.setBody(body)
+
+	return e
+}
+
+// newHTTPError builds an HTTPError from the response, without reading the body
+func newHTTPError(resp *http.Response, expectedCode int) *HTTPError {
+	e := &HTTPError{ExpectedCode: expectedCode}
+
+	if resp == nil {
+		return e
+	}
+
+	e.StatusCode = resp.StatusCode
+	e.Header = resp.Header
+
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+
+		if resp.Request.URL != nil {
+			e.URL = resp.Request.URL.Redacted()
+		}
+	}
+
+	return e
+}
+
+// setBody records up to MaxErrorBodySize bytes of the body, decoding it if it's a problem document
+func (e *HTTPError) setBody(body []byte) {
+	if int64(len(body)) > MaxErrorBodySize {
+		body = body[:MaxErrorBodySize]
+	}
+
+	e.Body = body
+
+	if isProblem(e.Header.Get(HeaderContentType)) {
+		var p Problem
+		if json.Unmarshal(body, &p) == nil {
+			e.Problem = &p
+		}
+	}
+}
+
+// isProblem returns true if the content type is application/problem+json
+func isProblem(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	return err == nil && mediaType == ContentTypeProblemJSON
+}
+
+// prefixedReadCloser replays the bytes already read from a body before the rest of it
+type prefixedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// errReader returns err, if set, once the bytes read before the error have been replayed
+type errReader struct {
+	err error
+}
+
+func (e *errReader) Read(_ []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	return 0, io.EOF
+}
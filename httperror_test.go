@@ -0,0 +1,115 @@
+package httpsling
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(HeaderContentType, ContentTypeProblemJSON)
+		w.Header().Set("X-Request-Id", "abc")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"type":"https://example.com/probs/out-of-credit","title":"You do not have enough credit.","status":403,"detail":"Your current balance is 30, but that costs 50."}`)) // nolint: errcheck
+	}))
+	defer ts.Close()
+
+	var out map[string]interface{}
+
+	resp, err := Receive(&out, Get(ts.URL, "/account?secret=1"), ExpectSuccessCode())
+	require.ErrorIs(t, err, ErrUnsuccessfulResponse)
+
+	defer resp.Body.Close()
+
+	var httpErr *HTTPError
+
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.MethodGet, httpErr.Method)
+	assert.Equal(t, ts.URL+"/account?secret=1", httpErr.URL)
+	assert.Equal(t, http.StatusForbidden, httpErr.StatusCode)
+	assert.Equal(t, 0, httpErr.ExpectedCode)
+	assert.Equal(t, "abc", httpErr.Header.Get("X-Request-Id"))
+	assert.Contains(t, string(httpErr.Body), "out-of-credit")
+
+	require.NotNil(t, httpErr.Problem)
+	assert.Equal(t, "https://example.com/probs/out-of-credit", httpErr.Problem.Type)
+	assert.Equal(t, http.StatusForbidden, httpErr.Problem.Status)
+	assert.Equal(t, "GET "+ts.URL+"/account?secret=1: unsuccessful response: server returned unsuccessful status code: 403: "+
+		"You do not have enough credit.: Your current balance is 30, but that costs 50.", err.Error())
+}
+
+func TestHTTPErrorBoundedBody(t *testing.T) {
+	limit := MaxErrorBodySize
+	MaxErrorBodySize = 8
+
+	defer func() {
+		MaxErrorBodySize = limit
+	}()
+
+	body := strings.Repeat("x", 32)
+
+	resp, err := MockDoer(http.StatusTeapot, Body(body)).Do(&http.Request{Method: http.MethodPost})
+	require.NoError(t, err)
+
+	httpErr := NewHTTPError(resp, http.StatusOK)
+	assert.Equal(t, strings.Repeat("x", 8), string(httpErr.Body))
+	assert.Nil(t, httpErr.Problem)
+	assert.Contains(t, httpErr.Error(), "POST: unsuccessful response: server returned unexpected status code. expected: 200, received: 418")
+
+	// the full body can still be read from the response
+	b, err := readBody(resp)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(b))
+}
+
+func TestReceiveHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(HeaderContentType, "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>bad gateway</html>")) // nolint: errcheck
+	}))
+	defer ts.Close()
+
+	// an unsuccessful response which can't be decoded returns an *HTTPError rather than the decoding error
+	var out map[string]interface{}
+
+	_, err := Receive(&out, Get(ts.URL))
+	require.ErrorIs(t, err, ErrUnsuccessfulResponse)
+	assert.ErrorIs(t, err, ErrUnsupportedContentType)
+
+	var httpErr *HTTPError
+
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusBadGateway, httpErr.StatusCode)
+	assert.Equal(t, "<html>bad gateway</html>", string(httpErr.Body))
+	assert.Equal(t, "text/html", httpErr.Header.Get(HeaderContentType))
+
+	// successful responses which can't be decoded still return the decoding error
+	ts2 := httptest.NewServer(MockHandler(http.StatusOK, ContentType("text/html"), Body("<html></html>")))
+	defer ts2.Close()
+
+	_, err = Receive(&out, Get(ts2.URL))
+	require.ErrorIs(t, err, ErrUnsupportedContentType)
+	assert.False(t, errors.As(err, &httpErr))
+
+	// ReceiveAs and ReceiveAsWithError share the same contract
+	_, _, err = ReceiveAs[map[string]interface{}](context.Background(), nil, Get(ts.URL))
+	require.ErrorIs(t, err, ErrUnsupportedContentType)
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusBadGateway, httpErr.StatusCode)
+
+	_, _, err = ReceiveAsWithError[map[string]interface{}, Problem](context.Background(), nil, Get(ts.URL))
+	require.ErrorIs(t, err, ErrUnsupportedContentType)
+
+	var errResp *ErrorResponse[Problem]
+
+	require.True(t, errors.As(err, &errResp))
+	assert.Equal(t, http.StatusBadGateway, errResp.HTTPError.StatusCode)
+}
@@ -2,55 +2,55 @@ package httptestutil
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
-	"net/http/httputil"
 	"os"
 
 	"github.com/felixge/httpsnoop"
+
+	"github.com/theopenlane/httpsling"
 )
 
 // DumpTo wraps an http.Handler in a new handler
-// the new handler dumps requests and responses to a writer, using the httputil.DumpRequest and
-// httputil.DumpResponse functions
+// the new handler dumps requests and responses to a writer in full, in HTTP/1.1 wire format
 func DumpTo(handler http.Handler, writer io.Writer) http.Handler {
+	return DumpToWith(handler, writer, &httpsling.DumpConfig{Redactor: &httpsling.Redactor{NoDefaultHeaders: true}, Binary: httpsling.BinaryRaw})
+}
+
+// DumpToWith wraps an http.Handler in a new handler which dumps requests and responses to a writer with the
+// given settings, like the httpsling.DumpWith middleware does on the client side
+func DumpToWith(handler http.Handler, writer io.Writer, config *httpsling.DumpConfig) http.Handler {
 	// use the same default as http.Server
 	if handler == nil {
 		handler = http.DefaultServeMux
 	}
 
+	d := httpsling.NewDumper(writer, config)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		dump, err := httputil.DumpRequest(r, true)
-		if err != nil {
-			_, _ = fmt.Fprintf(writer, "error dumping request: %#v", err)
-		} else {
-			_, _ = writer.Write(append(dump, []byte("\r\n")...))
-		}
-
-		ex := Exchange{}
-
-		w = httpsnoop.Wrap(w, hooks(&ex))
-
-		handler.ServeHTTP(w, r)
-
-		resp := http.Response{
-			Proto:         r.Proto,
-			ProtoMajor:    r.ProtoMajor,
-			ProtoMinor:    r.ProtoMinor,
-			StatusCode:    ex.StatusCode,
-			Header:        w.Header(),
-			Body:          io.NopCloser(bytes.NewReader(ex.ResponseBody.Bytes())),
-			ContentLength: int64(ex.ResponseBody.Len()),
-		}
-
-		d, err := httputil.DumpResponse(&resp, true)
-		if err != nil {
-			fmt.Fprintf(writer, "error dumping response: %#v", err) // nolint: errcheck
-		} else {
-			writer.Write(append(d, []byte("\r\n")...)) // nolint: errcheck
-		}
+		_, _ = d.Exchange(r, httpsling.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			ex := Exchange{}
+
+			sw := httpsnoop.Wrap(w, hooks(&ex))
+
+			handler.ServeHTTP(sw, r)
+
+			if ex.StatusCode == 0 {
+				ex.StatusCode = http.StatusOK
+			}
+
+			return &http.Response{
+				Proto:         r.Proto,
+				ProtoMajor:    r.ProtoMajor,
+				ProtoMinor:    r.ProtoMinor,
+				StatusCode:    ex.StatusCode,
+				Header:        w.Header(),
+				Body:          io.NopCloser(bytes.NewReader(ex.ResponseBody.Bytes())),
+				ContentLength: int64(ex.ResponseBody.Len()),
+				Request:       r,
+			}, nil
+		}))
 	})
 }
 
@@ -59,6 +59,11 @@ func Dump(ts *httptest.Server, to io.Writer) {
 	ts.Config.Handler = DumpTo(ts.Config.Handler, to)
 }
 
+// DumpWith writes requests and responses to the writer with the given settings
+func DumpWith(ts *httptest.Server, to io.Writer, config *httpsling.DumpConfig) {
+	ts.Config.Handler = DumpToWith(ts.Config.Handler, to, config)
+}
+
 // DumpToStdout writes requests and responses to os.Stdout
 func DumpToStdout(ts *httptest.Server) {
 	Dump(ts, os.Stdout)
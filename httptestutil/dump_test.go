@@ -117,3 +117,21 @@ func TestDumpToNilhandler(t *testing.T) {
 
 	require.NotEmpty(t, buf)
 }
+
+func TestDumpWith(t *testing.T) {
+	ts := httptest.NewServer(httpsling.MockHandler(201, httpsling.Body(`{"ping":"pong"}`), httpsling.JSON(false)))
+	defer ts.Close()
+
+	buf := bytes.NewBuffer(nil)
+	DumpWith(ts, buf, &httpsling.DumpConfig{Pretty: true})
+
+	resp, err := Requester(ts).Receive(httpsling.Post("/test?token=1"), httpsling.BearerAuth("secret"), httpsling.Body("ping"))
+	require.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	assert.Contains(t, buf.String(), "POST /test?token=1 HTTP/1.1")
+	assert.Contains(t, buf.String(), "Authorization: [REDACTED]")
+	assert.NotContains(t, buf.String(), "secret")
+	assert.Contains(t, buf.String(), "HTTP/1.1 201 Created")
+	assert.Contains(t, buf.String(), "{\n  \"ping\": \"pong\"\n}")
+}
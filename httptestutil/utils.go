@@ -1,6 +1,7 @@
 package httptestutil
 
 import (
+	"net/http"
 	"net/http/httptest"
 
 	"github.com/theopenlane/httpsling"
@@ -21,3 +22,13 @@ func Inspect(ts *httptest.Server) *Inspector {
 
 	return i
 }
+
+// ProblemHandler returns an http.Handler which responds with an RFC 9457 problem document for the status code
+func ProblemHandler(status int, detail string) http.Handler {
+	return httpsling.NewProblem(status, detail)
+}
+
+// Trace installs httpsling.TelemetryHandler on the test server, so requests it receives are traced and measured
+func Trace(ts *httptest.Server, config *httpsling.TelemetryConfig) {
+	ts.Config.Handler = httpsling.TelemetryHandler(config)(ts.Config.Handler)
+}
@@ -0,0 +1,237 @@
+package httpsling
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DefaultMaxLogBodySize is the number of body bytes logged when LogConfig doesn't configure it
+const DefaultMaxLogBodySize = 1024
+
+// LogConfig defines settings for the Log middleware
+type LogConfig struct {
+	// Logger receives the log records (default slog.Default())
+	Logger *slog.Logger
+	// Message is the message of the log records (default "http request")
+	Message string
+	// Level selects the level of the record of a request (default DefaultLogLevel)
+	Level func(resp *http.Response, err error) slog.Level
+	// Redactor removes secrets from the logged URL, headers and bodies; nil redacts DefaultRedactedHeaders
+	Redactor *Redactor
+	// Headers logs the request and response headers
+	Headers bool
+	// Bodies logs the request and response bodies, truncated to MaxBodySize bytes
+	Bodies bool
+	// MaxBodySize is the maximum number of body bytes logged (default DefaultMaxLogBodySize)
+	MaxBodySize int
+}
+
+func (c *LogConfig) normalize() {
+	if c.Logger == nil {
+		c.Logger = slog.Default()
+	}
+
+	if c.Message == "" {
+		c.Message = "http request"
+	}
+
+	if c.Level == nil {
+		c.Level = DefaultLogLevel
+	}
+
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = DefaultMaxLogBodySize
+	}
+}
+
+// DefaultLogLevel logs errors and 5xx responses at slog.LevelError, 4xx responses at slog.LevelWarn, and other
+// responses at slog.LevelInfo
+func DefaultLogLevel(resp *http.Response, err error) slog.Level {
+	switch {
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		return slog.LevelError
+	case resp.StatusCode >= http.StatusBadRequest:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Log logs each request with slog once its response headers are received: the method, redacted URL, status,
+// duration, retry attempt and body sizes, and optionally the headers and the start of the bodies. Unlike Dump,
+// it redacts credentials and only buffers the logged part of the bodies
+func Log(config *LogConfig) Middleware {
+	c := LogConfig{}
+	if config != nil {
+		c = *config
+	}
+
+	c.normalize()
+
+	rd := c.Redactor.compile()
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			start := time.Now()
+
+			reqAttrs := []any{slog.String("method", req.Method), slog.String("url", rd.url(req.URL))}
+
+			if req.ContentLength > 0 {
+				reqAttrs = append(reqAttrs, slog.Int64("size", req.ContentLength))
+			}
+
+			if c.Headers {
+				reqAttrs = append(reqAttrs, headerAttr(rd.header(req.Header)))
+			}
+
+			if c.Bodies {
+				body, logged, err := peekRequestBody(req, int64(c.MaxBodySize))
+				if err != nil {
+					return nil, err
+				}
+
+				req = logged
+
+				if body != nil {
+					reqAttrs = append(reqAttrs, bodyAttr(rd, req.Header.Get(HeaderContentType), body, c.MaxBodySize))
+				}
+			}
+
+			resp, err := next.Do(req)
+
+			attrs := []any{slog.Group("request", reqAttrs...), slog.Duration("duration", time.Since(start))}
+
+			attempt := RetryAttempt(ctx)
+			if attempt == 0 {
+				attempt = RetryAttempts(resp)
+			}
+
+			if attempt > 0 {
+				attrs = append(attrs, slog.Int("attempt", attempt))
+			}
+
+			if err != nil {
+				attrs = append(attrs, slog.Any("error", err))
+			}
+
+			if resp != nil {
+				respAttrs := []any{slog.Int("status", resp.StatusCode)}
+
+				if resp.ContentLength >= 0 {
+					respAttrs = append(respAttrs, slog.Int64("size", resp.ContentLength))
+				}
+
+				if c.Headers {
+					respAttrs = append(respAttrs, headerAttr(rd.header(resp.Header)))
+				}
+
+				if c.Bodies && resp.Body != nil && resp.Body != http.NoBody {
+					var body []byte
+
+					body, resp.Body = peekBody(resp.Body, int64(c.MaxBodySize))
+					respAttrs = append(respAttrs, bodyAttr(rd, resp.Header.Get(HeaderContentType), body, c.MaxBodySize))
+				}
+
+				attrs = append(attrs, slog.Group("response", respAttrs...))
+			}
+
+			c.Logger.Log(ctx, c.Level(resp, err), c.Message, attrs...)
+
+			return resp, err
+		})
+	}
+}
+
+// headerAttr returns the header as a group of attributes
+func headerAttr(h http.Header) slog.Attr {
+	attrs := make([]any, 0, len(h))
+
+	for _, key := range sortedHeaderKeys(h) {
+		values := h[key]
+		if len(values) == 1 {
+			attrs = append(attrs, slog.String(key, values[0]))
+		} else {
+			attrs = append(attrs, slog.Any(key, values))
+		}
+	}
+
+	return slog.Group("headers", attrs...)
+}
+
+// sortedHeaderKeys returns the keys of the header in order
+func sortedHeaderKeys(h http.Header) []string {
+	m := make(map[string]string, len(h))
+	for key := range h {
+		m[key] = ""
+	}
+
+	return sortedKeys(m)
+}
+
+// bodyAttr returns the redacted start of the body, marking bodies which were truncated
+func bodyAttr(rd *redactor, contentType string, body []byte, limit int) slog.Attr {
+	truncated := len(body) > limit
+	if truncated {
+		body = body[:limit]
+	}
+
+	logged := string(rd.body(contentType, body))
+	if truncated {
+		logged += "...(truncated)"
+	}
+
+	return slog.String("body", logged)
+}
+
+// peekRequestBody returns up to limit+1 bytes of the request body, or all of it if limit is negative, and a request
+// whose body still has all of it
+func peekRequestBody(req *http.Request, limit int64) ([]byte, *http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, req, nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		defer body.Close()
+
+		b, err := io.ReadAll(limitReader(body, limit))
+
+		return b, req, err
+	}
+
+	peeked := *req
+
+	var b []byte
+	b, peeked.Body = peekBody(req.Body, limit)
+
+	return b, &peeked, nil
+}
+
+// peekBody returns up to limit+1 bytes of the body, or all of it if limit is negative, and a body which still has
+// all of it
+func peekBody(body io.ReadCloser, limit int64) ([]byte, io.ReadCloser) {
+	b, err := io.ReadAll(limitReader(body, limit))
+
+	return b, &prefixedReadCloser{
+		Reader: io.MultiReader(bytes.NewReader(b), &errReader{err: err}, body),
+		Closer: body,
+	}
+}
+
+// limitReader reads up to limit+1 bytes, so reading more than limit bytes shows there is more; a negative limit
+// reads everything
+func limitReader(r io.Reader, limit int64) io.Reader {
+	if limit < 0 {
+		return r
+	}
+
+	return io.LimitReader(r, limit+1)
+}
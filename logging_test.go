@@ -0,0 +1,237 @@
+package httpsling_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+// logRecords returns the JSON log records written to the buffer
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func newLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestLog(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(200,
+		httpsling.Body(map[string]string{"token": "secret-token", "name": "meow"}),
+		httpsling.Header(httpsling.HeaderSetCookie, "session=secret")))
+	defer s.Close()
+
+	var buf bytes.Buffer
+
+	r := httptestutil.Requester(s, httpsling.Log(&httpsling.LogConfig{
+		Logger:  newLogger(&buf),
+		Headers: true,
+		Bodies:  true,
+		Redactor: &httpsling.Redactor{
+			Headers:     []string{"X-Api-Key"},
+			QueryParams: []string{"api_key"},
+			BodyFields:  []string{"password", "token"},
+		},
+	}))
+
+	var out map[string]string
+
+	_, err := r.Receive(&out,
+		httpsling.Post("/login"),
+		httpsling.QueryParam("api_key", "secret-key"),
+		httpsling.BearerAuth("secret-bearer"),
+		httpsling.Header("X-Api-Key", "secret-api-key"),
+		httpsling.Body(map[string]string{"user": "cat", "password": "secret-password"}))
+	require.NoError(t, err)
+
+	// the response body is still fully readable
+	assert.Equal(t, map[string]string{"token": "secret-token", "name": "meow"}, out)
+
+	assert.NotContains(t, buf.String(), "secret")
+
+	records := logRecords(t, &buf)
+	require.Len(t, records, 1)
+
+	record := records[0]
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "http request", record["msg"])
+	assert.Contains(t, record, "duration")
+
+	req := record["request"].(map[string]any)
+	assert.Equal(t, "POST", req["method"])
+	assert.Equal(t, s.URL+"/login?api_key=[REDACTED]", req["url"])
+	assert.Equal(t, "[REDACTED]", req["headers"].(map[string]any)["Authorization"])
+	assert.Equal(t, "[REDACTED]", req["headers"].(map[string]any)["X-Api-Key"])
+	assert.JSONEq(t, `{"user":"cat","password":"[REDACTED]"}`, req["body"].(string))
+	assert.InDelta(t, len(`{"password":"secret-password","user":"cat"}`), req["size"], 0)
+
+	resp := record["response"].(map[string]any)
+	assert.InDelta(t, 200, resp["status"], 0)
+	assert.Equal(t, "[REDACTED]", resp["headers"].(map[string]any)["Set-Cookie"])
+	assert.JSONEq(t, `{"token":"[REDACTED]","name":"meow"}`, resp["body"].(string))
+}
+
+func TestLogNoDefaultHeaders(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(200))
+	defer s.Close()
+
+	var buf bytes.Buffer
+
+	r := httptestutil.Requester(s, httpsling.Log(&httpsling.LogConfig{
+		Logger:   newLogger(&buf),
+		Headers:  true,
+		Redactor: &httpsling.Redactor{Headers: []string{"X-Api-Key"}, NoDefaultHeaders: true},
+	}))
+
+	_, err := r.Receive(nil, httpsling.BearerAuth("visible"), httpsling.Header("X-Api-Key", "secret"))
+	require.NoError(t, err)
+
+	records := logRecords(t, &buf)
+	require.Len(t, records, 1)
+
+	headers := records[0]["request"].(map[string]any)["headers"].(map[string]any)
+	assert.Equal(t, "Bearer visible", headers["Authorization"])
+	assert.Equal(t, "[REDACTED]", headers["X-Api-Key"])
+}
+
+func TestLogTruncatesBodies(t *testing.T) {
+	body := `{"token": "` + strings.Repeat("x", 100) + `"}`
+
+	s := httptest.NewServer(httpsling.MockHandler(200, httpsling.Body(body), httpsling.ContentType(httpsling.ContentTypeJSON)))
+	defer s.Close()
+
+	var buf bytes.Buffer
+
+	r := httptestutil.Requester(s, httpsling.Log(&httpsling.LogConfig{
+		Logger:      newLogger(&buf),
+		Bodies:      true,
+		MaxBodySize: 20,
+		Redactor:    &httpsling.Redactor{BodyFields: []string{"token"}},
+	}))
+
+	resp, err := r.Send(httpsling.Get())
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(b))
+
+	records := logRecords(t, &buf)
+	require.Len(t, records, 1)
+
+	// redaction applies to the truncated body too
+	assert.Equal(t, `{"token": "[REDACTED]"...(truncated)`, records[0]["response"].(map[string]any)["body"])
+}
+
+func TestLogLevels(t *testing.T) {
+	tests := []struct {
+		status int
+		level  string
+	}{
+		{status: 200, level: "INFO"},
+		{status: 302, level: "INFO"},
+		{status: 404, level: "WARN"},
+		{status: 503, level: "ERROR"},
+	}
+
+	for _, tc := range tests {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			s := httptest.NewServer(httpsling.MockHandler(tc.status))
+			defer s.Close()
+
+			var buf bytes.Buffer
+
+			r := httptestutil.Requester(s, httpsling.Log(&httpsling.LogConfig{Logger: newLogger(&buf)}))
+			r.Doer = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+			_, err := r.Receive(nil)
+			require.NoError(t, err)
+
+			records := logRecords(t, &buf)
+			require.Len(t, records, 1)
+			assert.Equal(t, tc.level, records[0]["level"])
+		})
+	}
+
+	t.Run("custom", func(t *testing.T) {
+		s := httptest.NewServer(httpsling.MockHandler(404))
+		defer s.Close()
+
+		var buf bytes.Buffer
+
+		r := httptestutil.Requester(s, httpsling.Log(&httpsling.LogConfig{
+			Logger: newLogger(&buf),
+			Level: func(*http.Response, error) slog.Level {
+				return slog.LevelDebug
+			},
+		}))
+
+		_, err := r.Receive(nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "DEBUG", logRecords(t, &buf)[0]["level"])
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		r := httpsling.MustNew(httpsling.WithDoer(httpsling.DoerFunc(func(*http.Request) (*http.Response, error) {
+			return nil, io.ErrUnexpectedEOF
+		})), httpsling.Log(&httpsling.LogConfig{Logger: newLogger(&buf)}))
+
+		_, err := r.Send(httpsling.Get("http://example.com"))
+		require.Error(t, err)
+
+		record := logRecords(t, &buf)[0]
+		assert.Equal(t, "ERROR", record["level"])
+		assert.Equal(t, io.ErrUnexpectedEOF.Error(), record["error"])
+		assert.NotContains(t, record, "response")
+	})
+}
+
+func TestLogRetryAttempts(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(503))
+	defer s.Close()
+
+	var buf bytes.Buffer
+
+	r := httptestutil.Requester(s,
+		httpsling.Retry(&httpsling.RetryConfig{MaxAttempts: 2, Backoff: httpsling.NoBackoff()}),
+		httpsling.Log(&httpsling.LogConfig{Logger: newLogger(&buf)}))
+
+	_, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	records := logRecords(t, &buf)
+	require.Len(t, records, 2)
+	assert.InDelta(t, 1, records[0]["attempt"], 0)
+	assert.InDelta(t, 2, records[1]["attempt"], 0)
+}
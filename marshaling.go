@@ -1,6 +1,7 @@
 package httpsling
 
 import (
+	"bytes"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"strings"
 
 	goquery "github.com/google/go-querystring/query"
+	"gopkg.in/yaml.v3"
 )
 
 var DefaultMarshaler Marshaler = &JSONMarshaler{}
@@ -108,6 +110,43 @@ func (m *XMLMarshaler) Apply(r *Requester) error {
 	return nil
 }
 
+// YAMLMarshaler implements Marshaler and Unmarshaler
+type YAMLMarshaler struct {
+	// Indent uses two space indentation instead of the default of four
+	Indent bool
+}
+
+// Unmarshal implements Unmarshaler
+func (*YAMLMarshaler) Unmarshal(data []byte, _ string, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
+
+// Marshal implements Marshaler
+func (m *YAMLMarshaler) Marshal(v interface{}) (data []byte, contentType string, err error) {
+	if !m.Indent {
+		data, err = yaml.Marshal(v)
+
+		return data, ContentTypeYAMLUTF8, err
+	}
+
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2) // nolint: mnd
+
+	if err = enc.Encode(v); err == nil {
+		err = enc.Close()
+	}
+
+	return buf.Bytes(), ContentTypeYAMLUTF8, err
+}
+
+// Apply implements Option
+func (m *YAMLMarshaler) Apply(r *Requester) error {
+	r.Marshaler = m
+	return nil
+}
+
 // TextUnmarshaler implements Marshaler and Unmarshaler
 type TextUnmarshaler struct {
 	Indent bool
@@ -175,7 +214,7 @@ type ContentTypeUnmarshaler struct {
 }
 
 // NewContentTypeUnmarshaler returns a new ContentTypeUnmarshaler preconfigured to
-// handle application/json and application/xml
+// handle application/json, application/problem+json, application/xml, application/yaml and text/plain
 func NewContentTypeUnmarshaler() *ContentTypeUnmarshaler {
 	return &ContentTypeUnmarshaler{
 		Unmarshalers: defaultUnmarshalers(),
@@ -184,9 +223,11 @@ func NewContentTypeUnmarshaler() *ContentTypeUnmarshaler {
 
 func defaultUnmarshalers() map[string]Unmarshaler {
 	return map[string]Unmarshaler{
-		ContentTypeJSON: &JSONMarshaler{},
-		ContentTypeXML:  &XMLMarshaler{},
-		ContentTypeText: &TextUnmarshaler{},
+		ContentTypeJSON:        &JSONMarshaler{},
+		ContentTypeProblemJSON: &JSONMarshaler{},
+		ContentTypeXML:         &XMLMarshaler{},
+		ContentTypeYAML:        &YAMLMarshaler{},
+		ContentTypeText:        &TextUnmarshaler{},
 	}
 }
 
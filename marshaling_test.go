@@ -83,6 +83,33 @@ func TestXMLMarshalerUnmarshal(t *testing.T) {
 	assert.Equal(t, testModel{"red", 30}, v)
 }
 
+func TestYAMLMarshalerMarshal(t *testing.T) {
+	m := YAMLMarshaler{}
+
+	v := map[string]interface{}{"color": "red", "sizes": map[string]int{"small": 1}}
+
+	data, contentType, err := m.Marshal(v)
+	require.NoError(t, err)
+	assert.Equal(t, "application/yaml;charset=utf-8", contentType)
+	assert.Equal(t, "color: red\nsizes:\n    small: 1\n", string(data))
+
+	m.Indent = true
+	data, _, err = m.Marshal(v)
+	require.NoError(t, err)
+	assert.Equal(t, "color: red\nsizes:\n  small: 1\n", string(data))
+}
+
+func TestYAMLMarshalerUnmarshal(t *testing.T) {
+	m := YAMLMarshaler{}
+
+	var v testModel
+
+	err := m.Unmarshal([]byte("color: red\ncount: 30\n"), "", &v)
+	require.NoError(t, err)
+
+	assert.Equal(t, testModel{"red", 30}, v)
+}
+
 func TestContentTypeUnmarshalerUnmarshal(t *testing.T) {
 	m := NewContentTypeUnmarshaler()
 	m.Unmarshalers["another/thing"] = &JSONMarshaler{}
@@ -107,6 +134,14 @@ func TestContentTypeUnmarshalerUnmarshal(t *testing.T) {
 			input:       `{"color":"red","count":30}`,
 			contentType: `another/thing`,
 		},
+		{
+			input:       "color: red\ncount: 30\n",
+			contentType: `application/yaml`,
+		},
+		{
+			input:       "color: red\ncount: 30\n",
+			contentType: `application/vnd.config+yaml; charset=utf-8`,
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.contentType, func(t *testing.T) {
@@ -2,13 +2,10 @@ package httpsling
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"net/http"
-	"net/http/httputil"
 	"os"
-
-	"github.com/theopenlane/utils/rout"
+	"sync"
 )
 
 // Middleware can be used to wrap Doers with additional functionality.
@@ -31,30 +28,10 @@ func Wrap(d Doer, m ...Middleware) Doer {
 	return d
 }
 
-// Dump dumps requests and responses to a writer.  Just intended for debugging.
+// Dump dumps requests and responses to a writer, in full and without redaction.  Just intended for debugging;
+// use DumpWith to redact secrets and limit the dumped bodies
 func Dump(w io.Writer) Middleware {
-	return func(next Doer) Doer {
-		return DoerFunc(func(req *http.Request) (*http.Response, error) {
-			dump, dumperr := httputil.DumpRequestOut(req, true)
-			if dumperr != nil {
-				io.WriteString(w, "Error dumping request: "+dumperr.Error()+"\n") // nolint: errcheck
-			} else {
-				io.WriteString(w, string(dump)+"\n") // nolint: errcheck
-			}
-
-			resp, err := next.Do(req)
-			if resp != nil {
-				dump, dumperr = httputil.DumpResponse(resp, true)
-				if dumperr != nil {
-					io.WriteString(w, "Error dumping response: "+dumperr.Error()+"\n") // nolint: errcheck
-				} else {
-					io.WriteString(w, string(dump)+"\n") // nolint: errcheck
-				}
-			}
-
-			return resp, err
-		})
-	}
+	return DumpWith(w, rawDumpConfig)
 }
 
 // DumpToStout dumps requests and responses to os.Stdout
@@ -85,7 +62,7 @@ func DumpToLog(logf func(a ...interface{})) Middleware {
 	return Dump(logFunc(logf))
 }
 
-// ExpectCode generates an error if the response's status code does not match
+// ExpectCode generates an *HTTPError if the response's status code does not match
 // the expected code.
 //
 // The response body will still be read and returned.
@@ -101,7 +78,7 @@ func ExpectCode(code int) Middleware {
 	}
 }
 
-// ExpectSuccessCode is middleware which generates an error if the response's status code is not between 200 and
+// ExpectSuccessCode is middleware which generates an *HTTPError if the response's status code is not between 200 and
 // 299.
 //
 // The response body will still be read and returned.
@@ -119,7 +96,10 @@ func ExpectSuccessCode() Middleware {
 
 type ctxKey int
 
-const expectCodeCtxKey ctxKey = iota
+const (
+	expectCodeCtxKey ctxKey = iota
+	retryAttemptCtxKey
+)
 
 const expectSuccessCode = -1
 
@@ -132,19 +112,10 @@ func (c *codeChecker) checkCode(resp *http.Response, err error) (*http.Response,
 	case err != nil, resp == nil:
 	case c.code == expectSuccessCode:
 		if resp.StatusCode < 200 || resp.StatusCode > 299 {
-			err = rout.HTTPErrorResponse(
-				fmt.Errorf("%w: server returned unsuccessful status code: %d",
-					ErrUnsuccessfulResponse,
-					resp.StatusCode,
-				))
+			err = NewHTTPError(resp, 0)
 		}
 	case c.code != resp.StatusCode:
-		err = rout.HTTPErrorResponse(
-			fmt.Errorf("%w: server returned unexpected status code. expected: %d, received: %d",
-				ErrUnsuccessfulResponse,
-				c.code,
-				resp.StatusCode,
-			))
+		err = NewHTTPError(resp, c.code)
 	}
 
 	return resp, err
@@ -159,3 +130,18 @@ func getCodeChecker(req *http.Request) (*http.Request, *codeChecker) {
 
 	return req, c
 }
+
+// releaseOnClose calls release once, when the body is first closed
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+// Close implements io.Closer
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+
+	return err
+}
@@ -0,0 +1,309 @@
+package httpsling
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrMultipartPartConsumed is returned when a one-shot multipart file part is read more than once
+var ErrMultipartPartConsumed = errors.New("multipart file part can only be read once")
+
+// Multipart is a multipart/form-data request body; it can be passed to Body() or applied
+// directly as an Option. The parts are streamed through an io.Pipe when the request is sent,
+// so file contents are never buffered in memory as a whole
+type Multipart struct {
+	// Fields are written as plain form fields, before any of the file parts
+	Fields url.Values
+	// Files are written as file parts, in order
+	Files []*MultipartFile
+
+	boundaryOnce sync.Once
+	boundary     string
+}
+
+// MultipartFile is a single file part of a Multipart body
+type MultipartFile struct {
+	// FieldName is the name of the form field the file is sent under
+	FieldName string
+	// FileName is the file name sent in the Content-Disposition header of the part
+	FileName string
+	// ContentType of the part; if empty it is guessed from the file name extension, falling back to application/octet-stream
+	ContentType string
+	// Header contains additional headers written with the part
+	Header textproto.MIMEHeader
+	// Open returns the contents of the part; it is called each time the body is (re)sent
+	Open func() (io.ReadCloser, error)
+
+	// oneShot is set when Open can only be called once, which makes the body unreplayable
+	oneShot bool
+}
+
+// NewMultipart returns a new Multipart body containing the supplied file parts
+func NewMultipart(files ...*MultipartFile) *Multipart {
+	return &Multipart{
+		Fields: url.Values{},
+		Files:  files,
+	}
+}
+
+// AddField adds a plain form field to the body
+func (m *Multipart) AddField(name, value string) *Multipart {
+	if m.Fields == nil {
+		m.Fields = url.Values{}
+	}
+
+	m.Fields.Add(name, value)
+
+	return m
+}
+
+// AddFile adds file parts to the body
+func (m *Multipart) AddFile(files ...*MultipartFile) *Multipart {
+	m.Files = append(m.Files, files...)
+
+	return m
+}
+
+// Boundary returns the boundary used to separate the parts, generating a random one on first use
+func (m *Multipart) Boundary() string {
+	m.boundaryOnce.Do(func() {
+		if m.boundary == "" {
+			m.boundary = multipart.NewWriter(io.Discard).Boundary()
+		}
+	})
+
+	return m.boundary
+}
+
+// ContentType returns the multipart/form-data content type, including the boundary parameter
+func (m *Multipart) ContentType() string {
+	return mime.FormatMediaType(ContentTypeMultipart, map[string]string{"boundary": m.Boundary()})
+}
+
+// GetBody returns a new reader which streams the encoded body; it can be used as http.Request.GetBody
+// so requests can be replayed, e.g. by the Retry middleware
+func (m *Multipart) GetBody() (io.ReadCloser, error) {
+	return &multipartReader{m: m}, nil
+}
+
+// Apply implements Option
+func (m *Multipart) Apply(r *Requester) error {
+	r.Body = m
+
+	return nil
+}
+
+// replayable returns true if the body can be sent more than once
+func (m *Multipart) replayable() bool {
+	for _, f := range m.Files {
+		if f.oneShot {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WriteTo encodes the body to w
+func (m *Multipart) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	mw := multipart.NewWriter(cw)
+
+	if err := mw.SetBoundary(m.Boundary()); err != nil {
+		return cw.n, fmt.Errorf("error setting multipart boundary: %w", err)
+	}
+
+	keys := make([]string, 0, len(m.Fields))
+	for key := range m.Fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range m.Fields[key] {
+			if err := mw.WriteField(key, value); err != nil {
+				return cw.n, fmt.Errorf("error writing multipart field %q: %w", key, err)
+			}
+		}
+	}
+
+	for _, f := range m.Files {
+		if err := f.writeTo(mw); err != nil {
+			return cw.n, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return cw.n, fmt.Errorf("error closing multipart writer: %w", err)
+	}
+
+	return cw.n, nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+func (f *MultipartFile) writeTo(mw *multipart.Writer) error {
+	if f.Open == nil {
+		return fmt.Errorf("%w: multipart file %q has no content", ErrNoFilesUploaded, f.FileName)
+	}
+
+	h := make(textproto.MIMEHeader, len(f.Header)+2) // nolint: mnd
+	for key, values := range f.Header {
+		h[key] = values
+	}
+
+	h.Set(HeaderContentDisposition,
+		fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(f.FieldName), escapeQuotes(f.FileName)))
+	h.Set(HeaderContentType, f.contentType())
+
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return fmt.Errorf("error creating multipart part %q: %w", f.FieldName, err)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("error opening multipart file %q: %w", f.FileName, err)
+	}
+
+	defer rc.Close()
+
+	if _, err := io.Copy(w, rc); err != nil {
+		return fmt.Errorf("error writing multipart file %q: %w", f.FileName, err)
+	}
+
+	return nil
+}
+
+func (f *MultipartFile) contentType() string {
+	if f.ContentType != "" {
+		return f.ContentType
+	}
+
+	if ct := mime.TypeByExtension(filepath.Ext(f.FileName)); ct != "" {
+		return ct
+	}
+
+	return ContentTypeApplicationOctetStream
+}
+
+// FileFromReader returns a file part which reads its contents from r; if r is an io.Seeker it is
+// rewound each time the body is sent, otherwise the part can only be sent once and retries are skipped
+func FileFromReader(fieldName, fileName string, r io.Reader) *MultipartFile {
+	f := &MultipartFile{
+		FieldName: fieldName,
+		FileName:  fileName,
+	}
+
+	if s, ok := r.(io.ReadSeeker); ok {
+		f.Open = func() (io.ReadCloser, error) {
+			if _, err := s.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+
+			return io.NopCloser(s), nil
+		}
+
+		return f
+	}
+
+	var once sync.Once
+
+	f.oneShot = true
+	f.Open = func() (rc io.ReadCloser, err error) {
+		err = ErrMultipartPartConsumed
+
+		once.Do(func() {
+			rc, err = io.NopCloser(r), nil
+		})
+
+		return rc, err
+	}
+
+	return f
+}
+
+// FileFromBytes returns a file part containing b
+func FileFromBytes(fieldName, fileName string, b []byte) *MultipartFile {
+	return &MultipartFile{
+		FieldName: fieldName,
+		FileName:  fileName,
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		},
+	}
+}
+
+// FileFromFS returns a file part which reads the named file from fsys each time the body is sent
+func FileFromFS(fieldName string, fsys fs.FS, name string) *MultipartFile {
+	return &MultipartFile{
+		FieldName: fieldName,
+		FileName:  path.Base(name),
+		Open: func() (io.ReadCloser, error) {
+			return fsys.Open(name)
+		},
+	}
+}
+
+// multipartReader lazily starts encoding the body into a pipe on the first Read, so
+// a request which is built but never sent doesn't leak a goroutine
+type multipartReader struct {
+	m    *Multipart
+	once sync.Once
+	pr   *io.PipeReader
+}
+
+func (r *multipartReader) start() {
+	pr, pw := io.Pipe()
+	r.pr = pr
+
+	go func() {
+		_, err := r.m.WriteTo(pw)
+		pw.CloseWithError(err)
+	}()
+}
+
+// Read implements io.Reader
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.once.Do(r.start)
+
+	return r.pr.Read(p)
+}
+
+// Close implements io.Closer
+func (r *multipartReader) Close() error {
+	r.once.Do(func() {
+		r.pr, _ = io.Pipe()
+	})
+
+	return r.pr.Close()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,152 @@
+package httpsling
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func multipartEchoServer(t *testing.T, calls *int32) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var sb strings.Builder
+
+		sb.WriteString("name=" + r.FormValue("name") + "\n")
+
+		for field, headers := range r.MultipartForm.File {
+			for _, fh := range headers {
+				f, err := fh.Open()
+				require.NoError(t, err)
+
+				b, err := io.ReadAll(f)
+				require.NoError(t, err)
+
+				f.Close()
+
+				sb.WriteString(field + ":" + fh.Filename + ":" + fh.Header.Get(HeaderContentType) + ":" + fh.Header.Get("X-Part") + ":" + string(b) + "\n")
+			}
+		}
+
+		// fail the first request so retries can be observed
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		w.Write([]byte(sb.String())) // nolint: errcheck
+	}))
+}
+
+func TestMultipart(t *testing.T) {
+	var calls int32
+
+	ts := multipartEchoServer(t, &calls)
+	defer ts.Close()
+
+	fsys := fstest.MapFS{
+		"evidence/report.json": &fstest.MapFile{Data: []byte(`{"ok":true}`)},
+	}
+
+	notes := FileFromReader("text", "notes.txt", strings.NewReader("some notes"))
+	notes.ContentType = ContentTypeText
+
+	upload := FileFromBytes("raw", "data.bin", []byte("raw bytes"))
+	upload.Header = map[string][]string{"X-Part": {"extra"}}
+
+	mp := NewMultipart(
+		FileFromFS("doc", fsys, "evidence/report.json"),
+		notes,
+		upload,
+	).AddField("name", "mitb")
+
+	var out string
+
+	resp, err := Receive(&out, Post(ts.URL), mp, Retry(&RetryConfig{Backoff: NoBackoff()}))
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	assert.Contains(t, out, "name=mitb\n")
+	assert.Contains(t, out, `doc:report.json:application/json::{"ok":true}`)
+	assert.Contains(t, out, "text:notes.txt:text/plain::some notes")
+	assert.Contains(t, out, "raw:data.bin:application/octet-stream:extra:raw bytes")
+}
+
+func TestMultipartRequest(t *testing.T) {
+	mp := NewMultipart(FileFromBytes("file", "a.txt", []byte("hello")))
+
+	req, err := Request(Post("http://example.com"), Body(mp))
+	require.NoError(t, err)
+
+	assert.Equal(t, "multipart/form-data; boundary="+mp.Boundary(), req.Header.Get(HeaderContentType))
+	require.NotNil(t, req.GetBody)
+
+	first, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+
+	body, err := req.GetBody()
+	require.NoError(t, err)
+
+	second, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.Contains(t, string(first), `Content-Disposition: form-data; name="file"; filename="a.txt"`)
+	assert.Contains(t, string(first), "hello")
+}
+
+func TestMultipartOneShotReader(t *testing.T) {
+	var calls int32
+
+	ts := multipartEchoServer(t, &calls)
+	defer ts.Close()
+
+	mp := NewMultipart(FileFromReader("file", "a.txt", &dummyReader{next: strings.NewReader("once")}))
+
+	req, err := Request(Post(ts.URL), mp)
+	require.NoError(t, err)
+	assert.Nil(t, req.GetBody)
+
+	// without a GetBody function the request is not retried
+	resp, err := Send(Post(ts.URL), mp, Retry(&RetryConfig{Backoff: NoBackoff()}))
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestMultipartClosedBeforeRead(t *testing.T) {
+	body, err := NewMultipart().AddField("a", "b").GetBody()
+	require.NoError(t, err)
+
+	require.NoError(t, body.Close())
+
+	_, err = body.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+type dummyReader struct {
+	next io.Reader
+}
+
+func (d *dummyReader) Read(p []byte) (n int, err error) {
+	return d.next.Read(p)
+}
@@ -0,0 +1,136 @@
+package httpsling
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2Error is the error response of an OAuth2 token endpoint (RFC 6749 section 5.2)
+type OAuth2Error struct {
+	// Code is the error code, e.g. invalid_client
+	Code string `json:"error"`
+	// Description is a human readable description of the error
+	Description string `json:"error_description,omitempty"`
+	// URI identifies a page describing the error
+	URI string `json:"error_uri,omitempty"`
+}
+
+// Error implements error
+func (e OAuth2Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+
+	return e.Code + ": " + e.Description
+}
+
+// OAuth2Config defines settings for the OAuth2 middleware
+type OAuth2Config struct {
+	// TokenURL is the URL of the token endpoint, resolved against the URL of the Requester
+	TokenURL string
+	// ClientID is the client identifier
+	ClientID string
+	// ClientSecret is the client secret
+	ClientSecret string
+	// ClientAuthInParams sends the client credentials in the form body instead of with basic auth
+	ClientAuthInParams bool
+	// Scopes are the requested scopes
+	Scopes []string
+	// RefreshToken, if set, obtains tokens with the refresh_token grant instead of the client_credentials grant;
+	// refresh tokens rotated by the token endpoint are used for the following requests
+	RefreshToken string
+	// EndpointParams are additional form parameters sent to the token endpoint, e.g. audience
+	EndpointParams url.Values
+	// Requester sends the token requests (default the DefaultRequester); it must not use the OAuth2 middleware itself
+	Requester *Requester
+	// ExpirySkew is how long before their expiry tokens are refreshed (default DefaultTokenExpirySkew)
+	ExpirySkew time.Duration
+}
+
+// OAuth2 authorizes requests with an access token obtained from a token endpoint with the client credentials or
+// refresh token grant. The token is cached until shortly before it expires, and concurrent requests wait for a
+// single refresh. If the upstream rejects the token with a 401 and an invalid_token WWW-Authenticate challenge,
+// a new token is fetched and the request is sent once more, provided its body can be replayed
+func OAuth2(config *OAuth2Config) Middleware {
+	return TokenAuth(OAuth2TokenSource(config))
+}
+
+// OAuth2TokenSource returns a CachedTokenSource fetching tokens from the token endpoint of the config
+func OAuth2TokenSource(config *OAuth2Config) TokenSource {
+	c := OAuth2Config{}
+	if config != nil {
+		c = *config
+	}
+
+	if c.Requester == nil {
+		c.Requester = &DefaultRequester
+	}
+
+	return CachedTokenSource(&oauth2TokenSource{config: c, refreshToken: c.RefreshToken}, c.ExpirySkew)
+}
+
+// oauth2TokenSource fetches a new token from the token endpoint on every call
+type oauth2TokenSource struct {
+	config OAuth2Config
+
+	mu           sync.Mutex
+	refreshToken string
+}
+
+// Token implements TokenSource
+func (s *oauth2TokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	form := url.Values{}
+
+	if s.refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", s.refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+
+	if len(s.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+
+	for key, values := range s.config.EndpointParams {
+		form[key] = values
+	}
+
+	opts := []Option{Post(s.config.TokenURL), Form(), Body(form), Accept(ContentTypeJSON)}
+
+	if s.config.ClientAuthInParams {
+		form.Set("client_id", s.config.ClientID)
+
+		if s.config.ClientSecret != "" {
+			form.Set("client_secret", s.config.ClientSecret)
+		}
+	} else if s.config.ClientID != "" {
+		opts = append(opts, BasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret)))
+	}
+
+	tok, _, err := ReceiveAsWithError[Token, OAuth2Error](ctx, s.config.Requester, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching oauth2 token: %w", err)
+	}
+
+	if tok.AccessToken == "" {
+		return nil, ErrMissingAccessToken
+	}
+
+	if tok.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	}
+
+	if tok.RefreshToken != "" {
+		s.refreshToken = tok.RefreshToken
+	}
+
+	return &tok, nil
+}
@@ -0,0 +1,281 @@
+package httpsling_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+// tokenServer issues numbered tokens, recording the form of each token request
+type tokenServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	forms     []map[string]string
+	expiresIn int
+	delay     time.Duration
+}
+
+func newTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	t.Helper()
+
+	ts := &tokenServer{expiresIn: expiresIn}
+
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+
+		time.Sleep(ts.delay)
+
+		form := map[string]string{}
+		for key := range r.PostForm {
+			form[key] = r.PostForm.Get(key)
+		}
+
+		if user, pass, ok := r.BasicAuth(); ok {
+			form["basic"] = user + ":" + pass
+		}
+
+		ts.mu.Lock()
+		ts.forms = append(ts.forms, form)
+		n := len(ts.forms)
+		ts.mu.Unlock()
+
+		if form["basic"] == "bad:client" {
+			w.Header().Set(httpsling.HeaderContentType, httpsling.ContentTypeJSON)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"unknown client"}`)) // nolint: errcheck
+
+			return
+		}
+
+		w.Header().Set(httpsling.HeaderContentType, httpsling.ContentTypeJSONUTF8)
+		fmt.Fprintf(w, `{"access_token":"t%d","token_type":"bearer","expires_in":%d,"refresh_token":"r%d"}`, n, ts.expiresIn, n+1)
+	}))
+
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func (ts *tokenServer) requests() []map[string]string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	return ts.forms
+}
+
+// authEcho responds with the Authorization header of the request
+var authEcho = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(httpsling.HeaderContentType, httpsling.ContentTypeText)
+	w.Write([]byte(r.Header.Get(httpsling.HeaderAuthorization))) // nolint: errcheck
+})
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	ts := newTokenServer(t, 3600)
+
+	s := httptest.NewServer(authEcho)
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.OAuth2(&httpsling.OAuth2Config{
+		TokenURL:       ts.URL + "/token",
+		ClientID:       "client",
+		ClientSecret:   "s3cr3t",
+		Scopes:         []string{"read", "write"},
+		EndpointParams: map[string][]string{"audience": {"api"}},
+	}))
+
+	for range 2 {
+		var out string
+
+		resp, err := r.Receive(&out)
+		require.NoError(t, err)
+
+		resp.Body.Close()
+
+		assert.Equal(t, "Bearer t1", out)
+	}
+
+	assert.Equal(t, []map[string]string{{
+		"grant_type": "client_credentials",
+		"scope":      "read write",
+		"audience":   "api",
+		"basic":      "client:s3cr3t",
+	}}, ts.requests())
+}
+
+func TestOAuth2ClientAuthInParams(t *testing.T) {
+	ts := newTokenServer(t, 3600)
+
+	s := httptest.NewServer(authEcho)
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.OAuth2(&httpsling.OAuth2Config{
+		TokenURL:           ts.URL,
+		ClientID:           "client",
+		ClientSecret:       "s3cr3t",
+		ClientAuthInParams: true,
+	}))
+
+	resp, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Equal(t, []map[string]string{{
+		"grant_type":    "client_credentials",
+		"client_id":     "client",
+		"client_secret": "s3cr3t",
+	}}, ts.requests())
+}
+
+func TestOAuth2SingleFlight(t *testing.T) {
+	ts := newTokenServer(t, 3600)
+	ts.delay = 20 * time.Millisecond
+
+	s := httptest.NewServer(authEcho)
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.OAuth2(&httpsling.OAuth2Config{TokenURL: ts.URL, ClientID: "client"}))
+
+	var wg sync.WaitGroup
+
+	for range 10 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			var out string
+
+			resp, err := r.Receive(&out)
+			assert.NoError(t, err)
+
+			resp.Body.Close()
+
+			assert.Equal(t, "Bearer t1", out)
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Len(t, ts.requests(), 1)
+}
+
+func TestOAuth2RefreshToken(t *testing.T) {
+	// tokens expire within the skew, so each request fetches a new one
+	ts := newTokenServer(t, 5)
+
+	s := httptest.NewServer(authEcho)
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.OAuth2(&httpsling.OAuth2Config{
+		TokenURL:     ts.URL,
+		ClientID:     "client",
+		RefreshToken: "r1",
+	}))
+
+	for i := range 3 {
+		var out string
+
+		resp, err := r.Receive(&out)
+		require.NoError(t, err)
+
+		resp.Body.Close()
+
+		assert.Equal(t, fmt.Sprintf("Bearer t%d", i+1), out)
+	}
+
+	// rotated refresh tokens are used for the next refresh
+	forms := ts.requests()
+	require.Len(t, forms, 3)
+
+	for i, form := range forms {
+		assert.Equal(t, "refresh_token", form["grant_type"])
+		assert.Equal(t, fmt.Sprintf("r%d", i+1), form["refresh_token"])
+	}
+}
+
+func TestOAuth2InvalidToken(t *testing.T) {
+	ts := newTokenServer(t, 3600)
+
+	var calls atomic.Int32
+
+	// the first token is revoked
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		if r.Header.Get(httpsling.HeaderAuthorization) == "Bearer t1" {
+			w.Header().Set(httpsling.HeaderWWWAuthenticate, `Bearer realm="api", error="invalid_token", error_description="revoked"`)
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set(httpsling.HeaderContentType, httpsling.ContentTypeText)
+		w.Write(b) // nolint: errcheck
+	}))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.OAuth2(&httpsling.OAuth2Config{TokenURL: ts.URL, ClientID: "client"}))
+
+	var out string
+
+	resp, err := r.Receive(&out, httpsling.Post(), httpsling.Body("payload"))
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "payload", out)
+	assert.EqualValues(t, 2, calls.Load())
+	assert.Len(t, ts.requests(), 2)
+
+	// a 401 without the invalid_token challenge is returned as is
+	unauthorized := httptest.NewServer(httpsling.MockHandler(http.StatusUnauthorized))
+	defer unauthorized.Close()
+
+	resp, err = r.Receive(nil, httpsling.Get(unauthorized.URL))
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Len(t, ts.requests(), 2)
+}
+
+func TestOAuth2Error(t *testing.T) {
+	ts := newTokenServer(t, 3600)
+
+	r := httpsling.MustNew(httpsling.OAuth2(&httpsling.OAuth2Config{
+		TokenURL:     ts.URL,
+		ClientID:     "bad",
+		ClientSecret: "client",
+	}))
+
+	_, err := r.Receive(nil, httpsling.Get("http://example.invalid")) // nolint: bodyclose
+	require.Error(t, err)
+
+	var oauthErr *httpsling.ErrorResponse[httpsling.OAuth2Error]
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, "invalid_client", oauthErr.Value.Code)
+	assert.Equal(t, "invalid_client: unknown client", oauthErr.Value.Error())
+	assert.Equal(t, http.StatusUnauthorized, oauthErr.Response.StatusCode)
+
+	// the decoded error can be retrieved directly
+	var tokenErr httpsling.OAuth2Error
+	require.ErrorAs(t, err, &tokenErr)
+	assert.Equal(t, "invalid_client", tokenErr.Code)
+	assert.ErrorIs(t, err, httpsling.ErrUnsuccessfulResponse)
+}
@@ -383,6 +383,15 @@ func XML(indent bool) Option {
 	)
 }
 
+// YAML sets Requester.Marshaler to the YAMLMarshaler
+func YAML(indent bool) Option {
+	return joinOpts(
+		WithMarshaler(&YAMLMarshaler{Indent: indent}),
+		ContentType(ContentTypeYAML),
+		Accept(ContentTypeYAML),
+	)
+}
+
 // Form sets Requester.Marshaler to the FormMarshaler which marshals the body into form-urlencoded
 func Form() Option {
 	return WithMarshaler(&FormMarshaler{})
@@ -429,6 +438,15 @@ func WithMaxFileSize(i int64) Option {
 	})
 }
 
+// WithMaxUploadSize sets the maximum total size of a multipart upload request
+func WithMaxUploadSize(i int64) Option {
+	return OptionFunc(func(r *Requester) error {
+		r.MaxUploadSize = i
+
+		return nil
+	})
+}
+
 // WithValidationFunc allows you to set a function that can be used to perform validations
 func WithValidationFunc(validationFunc ValidationFunc) Option {
 	return OptionFunc(func(r *Requester) error {
@@ -455,3 +473,30 @@ func WithFileErrorResponseHandler(errHandler ErrResponseHandler) Option {
 		return nil
 	})
 }
+
+// WithStorage sets the Storage that files parsed by the FileUploadMiddleware are persisted to; files are stored under
+// a random prefix followed by their name, or under the name from WithNameFuncGenerator if one is configured, in which
+// case uploads whose name is already stored are rejected with ErrStorageKeyExists
+func WithStorage(s Storage) Option {
+	return OptionFunc(func(r *Requester) error {
+		r.fileStorage = s
+
+		return nil
+	})
+}
+
+// WithFieldStorage sets the Storage that files uploaded in the given form field are persisted to, overriding WithStorage
+func WithFieldStorage(fieldName string, s Storage) Option {
+	return OptionFunc(func(r *Requester) error {
+		// copy the map so clones of the Requester are not modified
+		fieldStorage := make(map[string]Storage, len(r.fieldStorage)+1)
+		for key, value := range r.fieldStorage {
+			fieldStorage[key] = value
+		}
+
+		fieldStorage[fieldName] = s
+		r.fieldStorage = fieldStorage
+
+		return nil
+	})
+}
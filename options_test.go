@@ -516,6 +516,25 @@ func TestXML(t *testing.T) {
 	}
 }
 
+func TestYAML(t *testing.T) {
+	reqs, err := New(YAML(false))
+	require.NoError(t, err)
+
+	if assert.IsType(t, &YAMLMarshaler{}, reqs.Marshaler) {
+		assert.False(t, reqs.Marshaler.(*YAMLMarshaler).Indent)
+	}
+
+	assert.Equal(t, ContentTypeYAML, reqs.Header.Get(HeaderContentType))
+	assert.Equal(t, ContentTypeYAML, reqs.Header.Get(HeaderAccept))
+
+	err = reqs.Apply(YAML(true))
+	require.NoError(t, err)
+
+	if assert.IsType(t, &YAMLMarshaler{}, reqs.Marshaler) {
+		assert.True(t, reqs.Marshaler.(*YAMLMarshaler).Indent)
+	}
+}
+
 func TestForm(t *testing.T) {
 	reqs, err := New(Form())
 	require.NoError(t, err)
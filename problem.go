@@ -0,0 +1,172 @@
+package httpsling
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// problemMembers are the members defined by RFC 9457, all other members of a problem document are extensions
+var problemMembers = map[string]bool{"type": true, "title": true, "status": true, "detail": true, "instance": true}
+
+// Problem is an RFC 9457 problem details document, sent with the application/problem+json content type.
+// A Problem can be returned as an error, and served as an http.Handler
+type Problem struct {
+	// Type is a URI reference identifying the problem type
+	Type string `json:"type,omitempty"`
+	// Title is a short, human-readable summary of the problem type
+	Title string `json:"title,omitempty"`
+	// Status is the HTTP status code generated by the origin server
+	Status int `json:"status,omitempty"`
+	// Detail is a human-readable explanation specific to this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance is a URI reference identifying this occurrence of the problem
+	Instance string `json:"instance,omitempty"`
+	// Extensions contains any additional members of the problem document
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// NewProblem returns a Problem with the status code, its standard status text as the title, and the detail
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Error implements error
+func (p *Problem) Error() string {
+	msg := p.Title
+	if msg == "" {
+		msg = http.StatusText(p.Status)
+	}
+
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+
+	if p.Type != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, p.Type)
+	}
+
+	return msg
+}
+
+// MarshalJSON implements json.Marshaler, writing the extension members alongside the standard members
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+
+	data, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	members := make(map[string]interface{}, len(p.Extensions))
+
+	for key, value := range p.Extensions {
+		if !problemMembers[key] {
+			members[key] = value
+		}
+	}
+
+	ext, err := json.Marshal(members)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ext) <= 2 { // nolint: mnd
+		return data, nil
+	}
+
+	if len(data) <= 2 { // nolint: mnd
+		return ext, nil
+	}
+
+	// join the two objects: {"type":...} + {"ext":...} -> {"type":...,"ext":...}
+	return append(append(data[:len(data)-1], ','), ext[1:]...), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, collecting unknown members into Extensions
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	type problem Problem
+
+	var std problem
+	if err := json.Unmarshal(data, &std); err != nil {
+		return err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*p = Problem(std)
+	p.Extensions = nil
+
+	for key, raw := range members {
+		if problemMembers[key] {
+			continue
+		}
+
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+
+		if p.Extensions == nil {
+			p.Extensions = map[string]interface{}{}
+		}
+
+		p.Extensions[key] = value
+	}
+
+	return nil
+}
+
+// ServeHTTP implements http.Handler, writing the Problem as the response
+func (p *Problem) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	_ = WriteProblem(w, p)
+}
+
+// WriteProblem writes the Problem to the response with the application/problem+json content type; the response
+// status code is taken from the Problem, defaulting to 500
+func WriteProblem(w http.ResponseWriter, p *Problem) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("error marshaling problem: %w", err)
+	}
+
+	status := p.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set(HeaderContentType, ContentTypeProblemJSON)
+	w.Header().Set(HeaderXContentTypeOptions, "nosniff")
+	w.WriteHeader(status)
+
+	_, err = w.Write(data)
+
+	return err
+}
+
+// ProblemErrors is middleware which treats any response with an application/problem+json body as an error,
+// returning an *HTTPError which also matches the decoded *Problem with errors.As. The response body can still be read
+func ProblemErrors() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.Do(req)
+			if err != nil || resp == nil || !isProblem(resp.Header.Get(HeaderContentType)) {
+				return resp, err
+			}
+
+			httpErr := NewHTTPError(resp, 0)
+			if httpErr.Problem == nil {
+				return resp, err
+			}
+
+			return resp, httpErr
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package httpsling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemJSON(t *testing.T) {
+	p := Problem{
+		Type:   "https://example.com/probs/out-of-credit",
+		Title:  "You do not have enough credit.",
+		Status: http.StatusForbidden,
+		Extensions: map[string]interface{}{
+			"balance": 30,
+			"title":   "ignored, standard members win",
+		},
+	}
+
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"https://example.com/probs/out-of-credit","title":"You do not have enough credit.","status":403,"balance":30}`, string(data))
+
+	var out Problem
+	require.NoError(t, json.Unmarshal(data, &out))
+
+	assert.Equal(t, p.Type, out.Type)
+	assert.Equal(t, p.Title, out.Title)
+	assert.Equal(t, p.Status, out.Status)
+	assert.Equal(t, map[string]interface{}{"balance": float64(30)}, out.Extensions)
+
+	data, err = json.Marshal(Problem{Extensions: map[string]interface{}{"only": "extension"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"only":"extension"}`, string(data))
+
+	data, err = json.Marshal(Problem{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(data))
+}
+
+func TestProblemError(t *testing.T) {
+	assert.Equal(t, "Not Found: no such user", NewProblem(http.StatusNotFound, "no such user").Error())
+	assert.Equal(t, "Bad Request (https://example.com/probs/invalid)", (&Problem{Type: "https://example.com/probs/invalid", Status: 400}).Error())
+}
+
+func TestWriteProblem(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, WriteProblem(rec, &Problem{Detail: "boom"}))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, ContentTypeProblemJSON, rec.Header().Get(HeaderContentType))
+	assert.JSONEq(t, `{"detail":"boom"}`, rec.Body.String())
+}
+
+func TestProblemReceive(t *testing.T) {
+	ts := httptest.NewServer(&Problem{
+		Type:       "https://example.com/probs/out-of-credit",
+		Title:      "You do not have enough credit.",
+		Status:     http.StatusForbidden,
+		Extensions: map[string]interface{}{"balance": 30},
+	})
+	defer ts.Close()
+
+	// problem documents decode with the default unmarshaler
+	var out Problem
+
+	resp, err := Receive(&out, Get(ts.URL))
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusForbidden, out.Status)
+	assert.Equal(t, float64(30), out.Extensions["balance"])
+
+	// and can be treated as errors
+	resp, err = Receive(&out, Get(ts.URL), ProblemErrors())
+	require.ErrorIs(t, err, ErrUnsuccessfulResponse)
+
+	defer resp.Body.Close()
+
+	var problem *Problem
+
+	require.True(t, errors.As(err, &problem))
+	assert.Equal(t, "https://example.com/probs/out-of-credit", problem.Type)
+	assert.Equal(t, float64(30), problem.Extensions["balance"])
+
+	// the body can still be read
+	body, err := readBody(resp)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"balance":30`)
+
+	// the receive functions share the same contract
+	_, _, err = ReceiveAs[map[string]interface{}](context.Background(), nil, Get(ts.URL))
+	require.NoError(t, err)
+
+	_, _, err = ReceiveAs[map[string]interface{}](context.Background(), nil, Get(ts.URL), ProblemErrors())
+	require.True(t, errors.As(err, &problem))
+
+	_, _, err = ReceiveAsWithError[map[string]interface{}, Problem](context.Background(), nil, Get(ts.URL),
+		ProblemErrors())
+	require.True(t, errors.As(err, &problem))
+}
@@ -0,0 +1,214 @@
+package httpsling
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimitConfig defines settings for the RateLimit middleware
+type RateLimitConfig struct {
+	// Rate is the number of requests per second allowed for each key; 0 means no limit, which is only
+	// useful together with Adaptive
+	Rate float64
+	// Burst is the number of requests which can be sent at once after a quiet period (default the Rate rounded up, at least 1)
+	Burst int
+	// Key returns the key of the bucket a request is counted against, e.g. HostKey; nil counts all requests
+	// against a single bucket
+	Key KeyFunc
+	// Adaptive lowers the rate to what the upstream reports through its RateLimit, RateLimit-Remaining and
+	// X-RateLimit-Remaining headers and their reset counterparts, spreading the remaining requests over the rest of
+	// the window. When no requests remain, or the response is a 429, no requests are sent until the upstream
+	// asks to be retried
+	Adaptive bool
+}
+
+func (c *RateLimitConfig) normalize() {
+	if c.Burst < 1 {
+		c.Burst = max(1, int(math.Ceil(c.Rate)))
+	}
+
+	if c.Key == nil {
+		c.Key = func(*http.Request) string { return "" }
+	}
+}
+
+// RateLimit limits the rate requests are sent at with a token bucket per key: each request takes a token, and
+// waits for one to become available if the bucket is empty. Waiting is abandoned when the request context is done;
+// if the wait would outlast the deadline of the context, the request fails immediately
+func RateLimit(config *RateLimitConfig) Middleware {
+	c := RateLimitConfig{}
+	if config != nil {
+		c = *config
+	}
+
+	c.normalize()
+
+	var (
+		mu      sync.Mutex
+		buckets = map[string]*tokenBucket{}
+	)
+
+	bucket := func(key string) *tokenBucket {
+		mu.Lock()
+		defer mu.Unlock()
+
+		b, ok := buckets[key]
+		if !ok {
+			b = newTokenBucket(c.Rate, c.Burst)
+			buckets[key] = b
+		}
+
+		return b
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			b := bucket(c.Key(req))
+
+			if err := b.wait(req.Context()); err != nil {
+				return nil, err
+			}
+
+			resp, err := next.Do(req)
+			if err == nil && c.Adaptive {
+				b.adapt(resp)
+			}
+
+			return resp, err
+		})
+	}
+}
+
+// tokenBucket is a token bucket which refills at rate tokens per second up to burst tokens
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	// adapted is the rate reported by the upstream, which applies until adaptedUntil
+	adapted      float64
+	adaptedUntil time.Time
+	// blockedUntil is the time before which no tokens are handed out
+	blockedUntil time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// currentRate returns the rate in effect at now, or 0 if there is no limit
+func (b *tokenBucket) currentRate(now time.Time) float64 {
+	if now.Before(b.adaptedUntil) && (b.rate == 0 || b.adapted < b.rate) {
+		return b.adapted
+	}
+
+	return b.rate
+}
+
+// wait takes a token, waiting until one is available
+func (b *tokenBucket) wait(ctx context.Context) error {
+	d, limited := b.reserve(time.Now())
+	if !limited || d <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		b.cancel()
+
+		return fmt.Errorf("rate limit wait of %s exceeds the context deadline: %w", d, context.DeadlineExceeded)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		b.cancel()
+
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes a token, which may leave the bucket in debt, and returns how long to wait until the token is
+// available; limited is false if no rate applies
+func (b *tokenBucket) reserve(now time.Time) (d time.Duration, limited bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rate := b.currentRate(now)
+	blocked := b.blockedUntil.Sub(now)
+
+	if rate == 0 {
+		b.last = now
+
+		return blocked, blocked > 0
+	}
+
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	b.tokens--
+
+	if b.tokens < 0 {
+		d = time.Duration(-b.tokens / rate * float64(time.Second))
+	}
+
+	return max(d, blocked), true
+}
+
+// cancel returns a token taken by reserve which was not used
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+// adapt lowers the rate to the quota reported by the response headers
+func (b *tokenBucket) adapt(resp *http.Response) {
+	now := time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if d, ok := RetryAfter(resp); ok {
+			b.blockedUntil = now.Add(d)
+			b.tokens = min(b.tokens, 0)
+		}
+
+		return
+	}
+
+	remaining, ok := rateLimitRemaining(resp)
+	if !ok {
+		return
+	}
+
+	reset, ok := rateLimitReset(resp)
+	if !ok || reset <= 0 {
+		return
+	}
+
+	if remaining == 0 {
+		b.blockedUntil = now.Add(reset)
+		b.tokens = min(b.tokens, 0)
+
+		return
+	}
+
+	b.adapted = float64(remaining) / reset.Seconds()
+	b.adaptedUntil = now.Add(reset)
+	b.tokens = min(b.tokens, float64(remaining))
+}
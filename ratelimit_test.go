@@ -0,0 +1,136 @@
+package httpsling_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+func TestRateLimit(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(http.StatusOK))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.RateLimit(&httpsling.RateLimitConfig{Rate: 20, Burst: 2}))
+
+	t0 := time.Now()
+
+	// the burst is sent at once, the remaining requests wait for a token each
+	for range 5 {
+		resp, err := r.Receive(nil)
+		require.NoError(t, err)
+
+		resp.Body.Close()
+	}
+
+	assert.GreaterOrEqual(t, time.Since(t0), 140*time.Millisecond)
+	assert.Less(t, time.Since(t0), time.Second)
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	s1 := httptest.NewServer(httpsling.MockHandler(http.StatusOK))
+	defer s1.Close()
+
+	s2 := httptest.NewServer(httpsling.MockHandler(http.StatusOK))
+	defer s2.Close()
+
+	send := func(r *httpsling.Requester) time.Duration {
+		t0 := time.Now()
+
+		for _, u := range []string{s1.URL, s2.URL} {
+			resp, err := r.Receive(nil, httpsling.Get(u))
+			require.NoError(t, err)
+
+			resp.Body.Close()
+		}
+
+		return time.Since(t0)
+	}
+
+	// each host has its own bucket
+	assert.Less(t, send(httpsling.MustNew(httpsling.RateLimit(&httpsling.RateLimitConfig{Rate: 5, Burst: 1, Key: httpsling.HostKey}))), 100*time.Millisecond)
+
+	// by default all requests share a bucket
+	assert.GreaterOrEqual(t, send(httpsling.MustNew(httpsling.RateLimit(&httpsling.RateLimitConfig{Rate: 5, Burst: 1}))), 150*time.Millisecond)
+}
+
+func TestRateLimitContext(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(http.StatusOK))
+	defer s.Close()
+
+	i := httptestutil.Inspect(s)
+	r := httptestutil.Requester(s, httpsling.RateLimit(&httpsling.RateLimitConfig{Rate: 1}))
+
+	resp, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	// the wait would outlast the deadline, so the request fails without waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	t0 := time.Now()
+
+	_, err = r.ReceiveWithContext(ctx, nil) // nolint: bodyclose
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(t0), 50*time.Millisecond)
+
+	// canceling the context abandons the wait
+	ctx, cancel = context.WithCancel(context.Background())
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err = r.ReceiveWithContext(ctx, nil) // nolint: bodyclose
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, i.Drain(), 1)
+}
+
+func TestRateLimitAdaptive(t *testing.T) {
+	remaining := "5"
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(httpsling.HeaderXRateLimitRemaining, remaining)
+		w.Header().Set(httpsling.HeaderXRateLimitReset, "1")
+	}))
+	defer s.Close()
+
+	// without a configured rate the limit is entirely taken from the response headers
+	r := httptestutil.Requester(s, httpsling.RateLimit(&httpsling.RateLimitConfig{Adaptive: true}))
+
+	t0 := time.Now()
+
+	for range 3 {
+		resp, err := r.Receive(nil)
+		require.NoError(t, err)
+
+		resp.Body.Close()
+	}
+
+	// once the burst is spent, the 5 requests remaining in the 1s window are spread 200ms apart
+	assert.GreaterOrEqual(t, time.Since(t0), 150*time.Millisecond)
+
+	remaining = "0"
+
+	resp, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	// no requests are sent until the window resets
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = r.ReceiveWithContext(ctx, nil) // nolint: bodyclose
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,126 @@
+package httpsling
+
+import (
+	"context"
+	"time"
+)
+
+// ErrorResponse is returned by ReceiveAsWithError when the server responds with an unsuccessful status code;
+// Value holds the response body decoded into the error type
+type ErrorResponse[E any] struct {
+	// Response is the unsuccessful response
+	Response *Response
+	// Value is the decoded response body
+	Value E
+	// HTTPError describes the unsuccessful exchange
+	HTTPError *HTTPError
+}
+
+// Error implements error
+func (e *ErrorResponse[E]) Error() string {
+	return e.HTTPError.Error()
+}
+
+// Unwrap returns the HTTPError, so the error matches *HTTPError with errors.As and ErrUnsuccessfulResponse with
+// errors.Is, and the decoded Value if it is an error, like an OAuth2Error
+func (e *ErrorResponse[E]) Unwrap() []error {
+	if v, ok := any(e.Value).(error); ok {
+		return []error{e.HTTPError, v}
+	}
+
+	if v, ok := any(&e.Value).(error); ok {
+		return []error{e.HTTPError, v}
+	}
+
+	return []error{e.HTTPError}
+}
+
+// ReceiveAs sends a request with the Requester and decodes the response body into a new value of type T; if
+// r is nil the DefaultRequester is used. Like Receive, the body is decoded regardless of the status code, an
+// unsuccessful response whose body can't be decoded returns an *HTTPError, and an empty body leaves the value at
+// its zero value. If T is []byte, the raw body is returned
+func ReceiveAs[T any](ctx context.Context, r *Requester, opts ...Option) (T, *Response, error) {
+	var out T
+
+	r, resp, err := receive(ctx, r, opts...)
+	if err != nil {
+		return out, resp, err
+	}
+
+	if err := decodeError(resp.Raw, resp.Body, decodeResponse(r, resp, &out)); err != nil {
+		return out, resp, err
+	}
+
+	return out, resp, nil
+}
+
+// ReceiveAsWithError works like ReceiveAs, but only decodes successful responses into T; unsuccessful
+// responses are decoded into E and returned as an *ErrorResponse[E]; if the body can't be decoded into E, the
+// HTTPError of the ErrorResponse wraps the decoding error
+func ReceiveAsWithError[T, E any](ctx context.Context, r *Requester, opts ...Option) (T, *Response, error) {
+	var out T
+
+	r, resp, err := receive(ctx, r, opts...)
+	if err != nil {
+		return out, resp, err
+	}
+
+	if !resp.IsSuccess() {
+		errResp := &ErrorResponse[E]{Response: resp, HTTPError: newHTTPError(resp.Raw, 0)}
+		errResp.HTTPError.setBody(resp.Body)
+
+		errResp.HTTPError.cause = decodeResponse(r, resp, &errResp.Value)
+
+		return out, resp, errResp
+	}
+
+	if err := decodeResponse(r, resp, &out); err != nil {
+		return out, resp, err
+	}
+
+	return out, resp, nil
+}
+
+// receive applies the options to the Requester, sends the request and reads the whole response body, recording
+// the timing of the exchange. The body is read even if the middleware returned an error along with the response
+func receive(ctx context.Context, r *Requester, opts ...Option) (*Requester, *Response, error) {
+	if r == nil {
+		r = &DefaultRequester
+	}
+
+	r, err := r.withOpts(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start := time.Now()
+
+	resp, err := r.SendWithContext(ctx)
+
+	body, bodyReadError := readBody(resp)
+	if err == nil {
+		err = bodyReadError
+	}
+
+	return r, newResponse(resp, body, start), err
+}
+
+// decodeResponse unmarshals the response body into v using the Requester's Unmarshaler
+func decodeResponse(r *Requester, resp *Response, v interface{}) error {
+	if len(resp.Body) == 0 {
+		return nil
+	}
+
+	if b, ok := v.(*[]byte); ok {
+		*b = resp.Body
+
+		return nil
+	}
+
+	unmarshaler := DefaultUnmarshaler
+	if r.Unmarshaler != nil {
+		unmarshaler = r.Unmarshaler
+	}
+
+	return unmarshaler.Unmarshal(resp.Body, resp.Header.Get(HeaderContentType), v)
+}
@@ -0,0 +1,155 @@
+package httpsling_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+)
+
+type color struct {
+	Color string `json:"color"`
+}
+
+type apiError struct {
+	Message string `json:"message"`
+}
+
+func newColorServer() *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/color", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(httpsling.HeaderContentType, httpsling.ContentTypeJSON)
+		w.Write([]byte(`{"color":"red"}`)) // nolint: errcheck
+	})
+
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(httpsling.HeaderContentType, httpsling.ContentTypeJSON)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"no such color"}`)) // nolint: errcheck
+	})
+
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestReceiveAs(t *testing.T) {
+	ts := newColorServer()
+	defer ts.Close()
+
+	r := httpsling.MustNew(httpsling.URL(ts.URL))
+
+	out, resp, err := httpsling.ReceiveAs[color](context.Background(), r, httpsling.Get("/color"))
+	require.NoError(t, err)
+
+	assert.Equal(t, color{Color: "red"}, out)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, httpsling.ContentTypeJSON, resp.Header.Get(httpsling.HeaderContentType))
+	assert.Equal(t, `{"color":"red"}`, string(resp.Body))
+	assert.True(t, resp.IsSuccess())
+	assert.Positive(t, resp.Duration)
+	assert.False(t, resp.Start.IsZero())
+
+	// the options only apply to the single request
+	assert.Equal(t, ts.URL, r.URL.String())
+
+	raw, _, err := httpsling.ReceiveAs[[]byte](context.Background(), r, httpsling.Get("/color"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"color":"red"}`, string(raw))
+
+	// unsuccessful responses are still decoded, like Receive
+	out, resp, err = httpsling.ReceiveAs[color](context.Background(), r, httpsling.Get("/missing"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, color{}, out)
+
+	// empty bodies are not decoded
+	out, resp, err = httpsling.ReceiveAs[color](context.Background(), nil, httpsling.Get(ts.URL, "/empty"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, color{}, out)
+}
+
+func TestReceiveAsOptions(t *testing.T) {
+	ts := newColorServer()
+	defer ts.Close()
+
+	var called bool
+
+	_, _, err := httpsling.ReceiveAs[color](context.Background(), nil,
+		httpsling.Get(ts.URL, "/color"),
+		httpsling.UnmarshalFunc(func(_ []byte, _ string, _ interface{}) error {
+			called = true
+
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+	assert.True(t, called)
+
+	// middleware errors are returned with the read response
+	_, resp, err := httpsling.ReceiveAs[color](context.Background(), nil,
+		httpsling.Get(ts.URL, "/missing"),
+		httpsling.ExpectSuccessCode(),
+	)
+	require.ErrorIs(t, err, httpsling.ErrUnsuccessfulResponse)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, `{"message":"no such color"}`, string(resp.Body))
+}
+
+func TestReceiveAsWithError(t *testing.T) {
+	ts := newColorServer()
+	defer ts.Close()
+
+	r := httpsling.MustNew(httpsling.URL(ts.URL))
+
+	out, resp, err := httpsling.ReceiveAsWithError[color, apiError](context.Background(), r, httpsling.Get("/color"))
+	require.NoError(t, err)
+	assert.Equal(t, color{Color: "red"}, out)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	out, resp, err = httpsling.ReceiveAsWithError[color, apiError](context.Background(), r, httpsling.Get("/missing"))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, httpsling.ErrUnsuccessfulResponse)
+	assert.Contains(t, err.Error(), "404")
+	assert.Equal(t, color{}, out)
+
+	var errResp *httpsling.ErrorResponse[apiError]
+
+	require.True(t, errors.As(err, &errResp))
+	assert.Equal(t, "no such color", errResp.Value.Message)
+	assert.Equal(t, resp, errResp.Response)
+
+	var httpErr *httpsling.HTTPError
+
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.MethodGet, httpErr.Method)
+	assert.Equal(t, ts.URL+"/missing", httpErr.URL)
+	assert.Equal(t, `{"message":"no such color"}`, string(httpErr.Body))
+}
+
+func TestReceiveAsNoContentType(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header()["Content-Type"] = nil
+		w.Write([]byte(`{"color":"red"}`)) // nolint: errcheck
+	}))
+	defer ts.Close()
+
+	// like Receive, the content type isn't guessed when the response doesn't have one
+	var out color
+
+	_, receiveErr := httpsling.Receive(&out, httpsling.Get(ts.URL))
+	require.Error(t, receiveErr)
+
+	_, _, err := httpsling.ReceiveAs[color](context.Background(), nil, httpsling.Get(ts.URL))
+	require.EqualError(t, err, receiveErr.Error())
+}
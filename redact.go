@@ -0,0 +1,143 @@
+package httpsling
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces redacted values
+const Redacted = "[REDACTED]"
+
+// DefaultRedactedHeaders are the headers a Redactor always redacts, unless NoDefaultHeaders is set
+var DefaultRedactedHeaders = []string{HeaderAuthorization, HeaderCookie, HeaderSetCookie, HeaderProxyAuthorization}
+
+// Redactor removes secrets from requests and responses before they are logged or dumped
+type Redactor struct {
+	// Headers are the names of the headers whose values are redacted, in addition to DefaultRedactedHeaders
+	Headers []string
+	// NoDefaultHeaders stops redacting DefaultRedactedHeaders, so only Headers are redacted
+	NoDefaultHeaders bool
+	// QueryParams are the names of the query parameters whose values are redacted
+	QueryParams []string
+	// BodyFields are the names of the JSON object fields and form fields whose values are redacted from bodies,
+	// at any depth; redaction works on truncated bodies too
+	BodyFields []string
+}
+
+// redactor is a Redactor ready for use
+type redactor struct {
+	headers     map[string]bool
+	queryParams map[string]bool
+	jsonFields  *regexp.Regexp
+	formFields  *regexp.Regexp
+	// allQueryParams redacts the values of every query parameter
+	allQueryParams bool
+}
+
+// compile prepares the Redactor for use; a nil Redactor redacts the default headers
+func (r *Redactor) compile() *redactor {
+	c := Redactor{}
+	if r != nil {
+		c = *r
+	}
+
+	rd := &redactor{headers: map[string]bool{}, queryParams: map[string]bool{}}
+
+	if !c.NoDefaultHeaders {
+		for _, h := range DefaultRedactedHeaders {
+			rd.headers[http.CanonicalHeaderKey(h)] = true
+		}
+	}
+
+	for _, h := range c.Headers {
+		rd.headers[http.CanonicalHeaderKey(h)] = true
+	}
+
+	for _, p := range c.QueryParams {
+		rd.queryParams[p] = true
+	}
+
+	if len(c.BodyFields) > 0 {
+		fields := make([]string, len(c.BodyFields))
+		for i, f := range c.BodyFields {
+			fields[i] = regexp.QuoteMeta(f)
+		}
+
+		names := strings.Join(fields, "|")
+
+		// a string value, possibly cut off by truncation, or any other scalar value
+		rd.jsonFields = regexp.MustCompile(`("(?:` + names + `)"\s*:\s*)("(?:[^"\\]|\\.)*("|$)|[^\s,}\]"\[{]+)`)
+		rd.formFields = regexp.MustCompile(`((?:^|&)(?:` + names + `)=)[^&]*`)
+	}
+
+	return rd
+}
+
+// header returns a copy of the header with the values of redacted headers replaced
+func (rd *redactor) header(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+
+	redacted := h.Clone()
+
+	for key, values := range redacted {
+		if rd.headers[http.CanonicalHeaderKey(key)] {
+			for i := range values {
+				values[i] = Redacted
+			}
+		}
+	}
+
+	return redacted
+}
+
+// url returns the URL with credentials removed and the values of redacted query parameters replaced
+func (rd *redactor) url(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	redacted := *u
+
+	if redacted.User != nil {
+		redacted.User = url.User(redacted.User.Username())
+	}
+
+	if (rd.allQueryParams || len(rd.queryParams) > 0) && redacted.RawQuery != "" {
+		query := redacted.Query()
+
+		for key, values := range query {
+			if rd.allQueryParams || rd.queryParams[key] {
+				for i := range values {
+					values[i] = Redacted
+				}
+			}
+		}
+
+		redacted.RawQuery = strings.ReplaceAll(query.Encode(), url.QueryEscape(Redacted), Redacted)
+	}
+
+	return redacted.String()
+}
+
+// body returns the body with the values of redacted fields replaced, for JSON and form bodies
+func (rd *redactor) body(contentType string, body []byte) []byte {
+	if rd.jsonFields == nil || len(body) == 0 {
+		return body
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case mediaType == ContentTypeForm:
+		return rd.formFields.ReplaceAll(body, []byte("${1}"+url.QueryEscape(Redacted)))
+	case mediaType == ContentTypeJSON || strings.HasSuffix(mediaType, "+json"):
+		return rd.jsonFields.ReplaceAll(body, []byte(`${1}"`+Redacted+`"`))
+	default:
+		return body
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -33,7 +34,8 @@ type Requester struct {
 	Trailer http.Header
 	// QueryParams are added to the request, in addition to any query params already encoded in the URL
 	QueryParams url.Values
-	// Body can be set to a string, []byte, io.Reader, or a struct; if set to a string, []byte, or io.Reader, the value will be used as the body of the request
+	// Body can be set to a string, []byte, io.Reader, *Multipart, or a struct; if set to a string, []byte, or io.Reader, the value will be used as the body of the request
+	// If set to a *Multipart, the parts are streamed as a multipart/form-data body
 	// If set to a struct, the Marshaler will be used to marshal the value into the request body
 	Body interface{}
 	// Marshaler will be used to marshal the Body value into the body of the request.
@@ -44,14 +46,22 @@ type Requester struct {
 	Middleware []Middleware
 	// Unmarshaler will be used by the Receive methods to unmarshal the response body
 	Unmarshaler Unmarshaler
-	// MaxFileSize is the maximum size of a file to download
+	// MaxFileSize is the maximum size of a single uploaded file
 	MaxFileSize int64
+	// MaxUploadSize is the maximum total size of a multipart upload request
+	MaxUploadSize int64
 	// ValidationFunc is a function that can be used to validate the response
 	validationFunc ValidationFunc
 	// NameGeneratorFunc is a function that can be used to generate a name (added for files but could be used for other things)
 	fileNameFuncGenerator NameGeneratorFunc
 	// errorResponseHandler is a function that can be used to handle errors when a file upload fails
 	fileUploaderrorResponseHandler ErrResponseHandler
+	// fileStorage is the Storage uploaded files are persisted to, unless overridden for the form field by fieldStorage
+	fileStorage Storage
+	// fieldStorage maps form fields to the Storage their uploaded files are persisted to
+	fieldStorage map[string]Storage
+	// digests maps the digest headers added to requests with a body to their algorithms
+	digests map[string][]string
 }
 
 // New returns a new Requester, applying all options
@@ -103,6 +113,7 @@ func (r *Requester) Clone() *Requester {
 	req := *r
 	req.Header = r.Header.Clone()
 	req.Trailer = r.Trailer.Clone()
+	req.digests = maps.Clone(r.digests)
 	req.URL = cloneURL(r.URL)
 	req.QueryParams = cloneValues(r.QueryParams)
 
@@ -126,6 +137,21 @@ func (r *Requester) RequestWithContext(ctx context.Context, opts ...Option) (*ht
 		return nil, err
 	}
 
+	var digests http.Header
+
+	if len(requester.digests) > 0 && bodyData != nil {
+		b, err := io.ReadAll(bodyData)
+		if err != nil {
+			return nil, fmt.Errorf("error reading body: %w", err)
+		}
+
+		if digests, err = digestHeaders(requester.digests, b); err != nil {
+			return nil, err
+		}
+
+		bodyData = bytes.NewReader(b)
+	}
+
 	requestURL := ""
 	if requester.URL != nil {
 		requestURL = requester.URL.String()
@@ -142,6 +168,8 @@ func (r *Requester) RequestWithContext(ctx context.Context, opts ...Option) (*ht
 
 	if requester.GetBody != nil {
 		req.GetBody = requester.GetBody
+	} else if mp, ok := requester.Body.(*Multipart); ok && mp.replayable() {
+		req.GetBody = mp.GetBody
 	}
 
 	if requester.Host != "" {
@@ -164,6 +192,10 @@ func (r *Requester) RequestWithContext(ctx context.Context, opts ...Option) (*ht
 		req.Header.Set(HeaderContentType, contentType)
 	}
 
+	for key, values := range digests {
+		req.Header[key] = values
+	}
+
 	if len(requester.QueryParams) > 0 {
 		req.URL.RawQuery = requester.getQueryParams(req)
 	}
@@ -192,6 +224,10 @@ func (r *Requester) getRequestBody() (body io.Reader, contentType string, _ erro
 	switch v := r.Body.(type) {
 	case nil:
 		return nil, "", nil
+	case *Multipart:
+		body, err := v.GetBody()
+
+		return body, v.ContentType(), err
 	case io.Reader:
 		return v, "", nil
 	case string:
@@ -255,7 +291,9 @@ func (r *Requester) Do(req *http.Request) (*http.Response, error) {
 	return resp, err
 }
 
-// Receive creates a new HTTP request and returns the response
+// Receive creates a new HTTP request and returns the response; the body is decoded into into regardless of the status
+// code. Unsuccessful responses whose body can't be decoded return an *HTTPError, like responses rejected by
+// middleware such as ExpectCode or ProblemErrors
 func (r *Requester) Receive(into interface{}, opts ...Option) (resp *http.Response, err error) {
 	return r.ReceiveWithContext(context.Background(), into, opts...)
 }
@@ -289,16 +327,32 @@ func (r *Requester) ReceiveWithContext(ctx context.Context, into interface{}, op
 	}
 
 	// if the into is not nil, unmarshal the body into it
+	var decodeErr error
+
 	if into != nil {
 		unmarshaler := r.Unmarshaler
 		if unmarshaler == nil {
 			unmarshaler = DefaultUnmarshaler
 		}
 
-		err = unmarshaler.Unmarshal(body, resp.Header.Get(HeaderContentType), into)
+		decodeErr = unmarshaler.Unmarshal(body, resp.Header.Get(HeaderContentType), into)
 	}
 
-	return resp, err
+	return resp, decodeError(resp, body, decodeErr)
+}
+
+// decodeError returns the error of decoding the body of the response; an unsuccessful response which can't be
+// decoded, like an HTML error page, is described by an *HTTPError wrapping the decoding error
+func decodeError(resp *http.Response, body []byte, decodeErr error) error {
+	if decodeErr == nil || IsSuccess(resp) {
+		return decodeErr
+	}
+
+	httpErr := newHTTPError(resp, 0)
+	httpErr.setBody(body)
+	httpErr.cause = decodeErr
+
+	return httpErr
 }
 
 // readBody reads the body of an HTTP response
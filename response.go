@@ -1,6 +1,9 @@
 package httpsling
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
 // IsSuccess checks if the response status code indicates success
 func IsSuccess(resp *http.Response) bool {
@@ -8,3 +11,44 @@ func IsSuccess(resp *http.Response) bool {
 
 	return code >= http.StatusOK && code <= http.StatusIMUsed
 }
+
+// Response is the result of a request sent with ReceiveAs, holding the fully read response body
+type Response struct {
+	// Raw is the underlying http response; its body has already been read and closed
+	Raw *http.Response
+	// StatusCode is the status code of the response
+	StatusCode int
+	// Header contains the response headers
+	Header http.Header
+	// Body contains the raw bytes of the response body
+	Body []byte
+	// Start is the time the request was sent
+	Start time.Time
+	// Duration is the time between sending the request and reading the whole response body
+	Duration time.Duration
+	// Attempts is the number of attempts made by the Retry middleware, or 0 if it wasn't used
+	Attempts int
+}
+
+// newResponse wraps resp, which may be nil if the request failed before a response was received
+func newResponse(resp *http.Response, body []byte, start time.Time) *Response {
+	r := &Response{
+		Raw:      resp,
+		Body:     body,
+		Start:    start,
+		Duration: time.Since(start),
+	}
+
+	if resp != nil {
+		r.StatusCode = resp.StatusCode
+		r.Header = resp.Header
+		r.Attempts = RetryAttempts(resp)
+	}
+
+	return r
+}
+
+// IsSuccess checks if the response status code indicates success
+func (r *Response) IsSuccess() bool {
+	return r.Raw != nil && IsSuccess(r.Raw)
+}
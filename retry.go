@@ -2,6 +2,7 @@ package httpsling
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -24,6 +25,9 @@ var DefaultBackoff = ExponentialBackoff{
 	MaxDelay:   120 * time.Second, // nolint: mnd
 }
 
+// DefaultMaxRetryAfter is the default upper bound of waits requested by Retry-After and rate limit reset headers
+const DefaultMaxRetryAfter = 2 * time.Minute
+
 // DefaultShouldRetry is the default ShouldRetryer
 func DefaultShouldRetry(_ int, _ *http.Request, resp *http.Response, err error) bool {
 	var netError net.Error
@@ -63,6 +67,21 @@ type RetryConfig struct {
 	Backoff Backoffer
 	// ReadResponse will ensure the entire response is read before considering the request a success
 	ReadResponse bool
+	// MaxRetryAfter caps how long to wait when the response includes a Retry-After or rate limit reset header
+	// (default DefaultMaxRetryAfter); a negative value ignores those headers. It is not used if Backoff is a ResponseBackoffer
+	MaxRetryAfter time.Duration
+	// MaxElapsedTime is the total time budget across all attempts, including reading the response body; every
+	// attempt's context has a deadline at the end of the budget, and no retry is started if its backoff would end
+	// after the budget is spent. 0 means no limit
+	MaxElapsedTime time.Duration
+	// Budget limits retries to a ratio of successful requests; it can be shared by many Retry middlewares
+	Budget *RetryBudget
+	// OnRetry is called after an attempt failed, before waiting Delay to make the next attempt
+	OnRetry func(info RetryAttemptInfo)
+	// OnGiveUp is called when the final attempt failed, or when an attempt failed and should be retried but the
+	// time budget or the retry budget are exhausted. The final attempt isn't passed to ShouldRetry; it failed if it
+	// returned an error or DefaultShouldRetry would retry its response
+	OnGiveUp func(info RetryAttemptInfo)
 }
 
 func (c *RetryConfig) normalize() {
@@ -77,6 +96,26 @@ func (c *RetryConfig) normalize() {
 	if c.MaxAttempts < 1 {
 		c.MaxAttempts = 3
 	}
+
+	if c.MaxRetryAfter == 0 {
+		c.MaxRetryAfter = DefaultMaxRetryAfter
+	}
+}
+
+// delay returns how long to wait before the next attempt; if the Backoff can inspect the response it is
+// used as is, otherwise waits requested by the response headers take precedence over the Backoff
+func (c *RetryConfig) delay(attempt int, req *http.Request, resp *http.Response, err error) time.Duration {
+	if b, ok := c.Backoff.(ResponseBackoffer); ok {
+		return b.BackoffResponse(attempt, req, resp, err)
+	}
+
+	if c.MaxRetryAfter > 0 {
+		if d, ok := RetryAfter(resp); ok {
+			return min(d, c.MaxRetryAfter)
+		}
+	}
+
+	return c.Backoff.Backoff(attempt)
 }
 
 // ShouldRetryer evaluates whether an HTTP request should be retried
@@ -118,6 +157,26 @@ func (b BackofferFunc) Backoff(attempt int) time.Duration {
 	return b(attempt)
 }
 
+// ResponseBackoffer is a Backoffer which can also inspect the request, and the response or error of the failed
+// attempt, e.g. to honor headers sent by the server
+type ResponseBackoffer interface {
+	Backoffer
+	BackoffResponse(attempt int, req *http.Request, resp *http.Response, err error) time.Duration
+}
+
+// ResponseBackofferFunc adapts a function to the ResponseBackoffer interface
+type ResponseBackofferFunc func(attempt int, req *http.Request, resp *http.Response, err error) time.Duration
+
+// Backoff implements Backoffer
+func (b ResponseBackofferFunc) Backoff(attempt int) time.Duration {
+	return b(attempt, nil, nil, nil)
+}
+
+// BackoffResponse implements ResponseBackoffer
+func (b ResponseBackofferFunc) BackoffResponse(attempt int, req *http.Request, resp *http.Response, err error) time.Duration {
+	return b(attempt, req, resp, err)
+}
+
 // ExponentialBackoff defines the configuration options for an exponential backoff strategy
 type ExponentialBackoff struct {
 	// BaseDelay is the amount of time to backoff after the first failure
@@ -175,7 +234,9 @@ func ConstantBackoffWithJitter(delay time.Duration) *ExponentialBackoff {
 }
 
 // Retry retries the http request under certain conditions - the number of retries,
-// retry conditions, and the time to sleep between retries can be configured
+// retry conditions, and the time to sleep between retries can be configured.
+// Unless the Backoff is a ResponseBackoffer, the Retry-After and rate limit reset headers of the
+// response are honored, capped by MaxRetryAfter
 func Retry(config *RetryConfig) Middleware {
 	c := DefaultRetryConfig
 	if config != nil {
@@ -194,41 +255,189 @@ func Retry(config *RetryConfig) Middleware {
 				resp    *http.Response
 				err     error
 				attempt int
+				history []RetryAttemptInfo
+				start   = time.Now()
+				ctx     = req.Context()
+				cancel  context.CancelFunc
 			)
 
+			if c.MaxElapsedTime > 0 {
+				ctx, cancel = context.WithDeadline(ctx, start.Add(c.MaxElapsedTime))
+			}
+
+			giveUp := func(info RetryAttemptInfo) {
+				if c.OnGiveUp != nil {
+					c.OnGiveUp(info)
+				}
+			}
+
 			for {
-				resp, err = next.Do(req)
 				attempt++
+				req = req.WithContext(context.WithValue(ctx, retryAttemptCtxKey, attempt))
+
+				attemptStart := time.Now()
+				resp, err = next.Do(req)
 
 				if err == nil && c.ReadResponse {
 					resp.Body, err = bufRespBody(resp.Body)
 				}
 
-				if attempt >= c.MaxAttempts || !c.ShouldRetry.ShouldRetry(attempt, req, resp, err) {
+				info := RetryAttemptInfo{
+					Attempt:  attempt,
+					Request:  req,
+					Response: resp,
+					Err:      err,
+					Duration: time.Since(attemptStart),
+				}
+
+				if attempt >= c.MaxAttempts {
+					// the final attempt isn't passed to ShouldRetry; the default rules tell whether it failed
+					failed := err != nil || DefaultShouldRetry(attempt, req, resp, err)
+					if !failed {
+						c.Budget.success()
+					}
+
+					history = append(history, info)
+
+					if failed {
+						giveUp(info)
+					}
+
+					break
+				}
+
+				if !c.ShouldRetry.ShouldRetry(attempt, req, resp, err) {
+					if err == nil {
+						c.Budget.success()
+					}
+
+					history = append(history, info)
+
+					break
+				}
+
+				info.Delay = c.delay(attempt, req, resp, err)
+				history = append(history, info)
+
+				if c.MaxElapsedTime > 0 && time.Since(start)+info.Delay > c.MaxElapsedTime || !c.Budget.withdraw() {
+					history[len(history)-1].Delay = 0
+					info.Delay = 0
+					giveUp(info)
+
 					break
 				}
 
+				if c.OnRetry != nil {
+					c.OnRetry(info)
+				}
+
 				if resp != nil {
 					drain(resp.Body)
 				}
 
 				req, err = resetRequest(req)
 				if err != nil {
-					return resp, err
+					releaseDeadline(nil, cancel)
+
+					return resp, &RetryError{Attempts: attempt, Err: err, History: history}
 				}
 
 				select {
-				case <-req.Context().Done():
-					return nil, req.Context().Err()
-				case <-time.After(c.Backoff.Backoff(attempt)):
+				case <-ctx.Done():
+					releaseDeadline(nil, cancel)
+
+					return nil, &RetryError{Attempts: attempt, Err: ctx.Err(), History: history}
+				case <-time.After(info.Delay):
 				}
 			}
 
-			return resp, err
+			releaseDeadline(resp, cancel)
+
+			if err != nil {
+				return resp, &RetryError{Attempts: attempt, Err: err, History: history}
+			}
+
+			return resp, nil
 		})
 	}
 }
 
+// releaseDeadline cancels the context of the time budget, if there is one, once the body of the response is closed,
+// as the deadline also applies to reading the body
+func releaseDeadline(resp *http.Response, cancel context.CancelFunc) {
+	switch {
+	case cancel == nil:
+	case resp == nil || resp.Body == nil:
+		cancel()
+	default:
+		resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: cancel}
+	}
+}
+
+// RetryAttemptInfo describes a single attempt made by the Retry middleware
+type RetryAttemptInfo struct {
+	// Attempt is the attempt number, starting at 1
+	Attempt int
+	// Request is the request sent in the attempt
+	Request *http.Request
+	// Response is the response of the attempt, or nil if it failed with an error. The body of responses
+	// which are retried is drained after OnRetry returns
+	Response *http.Response
+	// Err is the error of the attempt
+	Err error
+	// Delay is how long the middleware waits before the next attempt; 0 if no further attempt is made
+	Delay time.Duration
+	// Duration is how long the attempt took
+	Duration time.Duration
+}
+
+// StatusCode returns the status code of the response of the attempt, or 0 if it failed with an error
+func (i RetryAttemptInfo) StatusCode() int {
+	if i.Response == nil {
+		return 0
+	}
+
+	return i.Response.StatusCode
+}
+
+// RetryError is returned by the Retry middleware when the request ultimately failed with an error
+type RetryError struct {
+	// Attempts is the number of attempts made
+	Attempts int
+	// Err is the error of the final attempt
+	Err error
+	// History describes every attempt made, in order
+	History []RetryAttemptInfo
+}
+
+// Error implements error
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%s (after %d attempts)", e.Err, e.Attempts)
+}
+
+// Unwrap returns the error of the final attempt
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAttempt returns the attempt number of the request the context belongs to, starting at 1, or 0 if the
+// request was not sent through the Retry middleware. It can be used by middleware installed after Retry
+func RetryAttempt(ctx context.Context) int {
+	attempt, _ := ctx.Value(retryAttemptCtxKey).(int)
+
+	return attempt
+}
+
+// RetryAttempts returns the number of attempts the Retry middleware made to get the response, or 0 if the
+// response was not received through the Retry middleware
+func RetryAttempts(resp *http.Response) int {
+	if resp == nil || resp.Request == nil {
+		return 0
+	}
+
+	return RetryAttempt(resp.Request.Context())
+}
+
 func bodyEmpty(req *http.Request) bool {
 	return req.Body != nil && req.Body != http.NoBody && req.GetBody == nil
 }
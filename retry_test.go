@@ -6,7 +6,9 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
@@ -645,3 +647,433 @@ func TestRetryReadResponse(t *testing.T) {
 	// should have taken 3 tries
 	assert.Equal(t, 3, count)
 }
+
+func TestRetryAfter(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		status   int
+		header   map[string]string
+		expected time.Duration
+		ok       bool
+	}{
+		{
+			name:   "no headers",
+			status: 503,
+		},
+		{
+			name:     "delta seconds",
+			status:   503,
+			header:   map[string]string{httpsling.HeaderRetryAfter: "30"},
+			expected: 30 * time.Second,
+			ok:       true,
+		},
+		{
+			name:     "http date",
+			status:   503,
+			header:   map[string]string{httpsling.HeaderRetryAfter: future.UTC().Format(http.TimeFormat)},
+			expected: time.Hour,
+			ok:       true,
+		},
+		{
+			name:     "http date in the past",
+			status:   503,
+			header:   map[string]string{httpsling.HeaderRetryAfter: "Wed, 21 Oct 2015 07:28:00 GMT"},
+			expected: 0,
+			ok:       true,
+		},
+		{
+			name:   "invalid",
+			status: 503,
+			header: map[string]string{httpsling.HeaderRetryAfter: "soon"},
+		},
+		{
+			name:     "ratelimit reset",
+			status:   429,
+			header:   map[string]string{httpsling.HeaderRateLimitReset: "12"},
+			expected: 12 * time.Second,
+			ok:       true,
+		},
+		{
+			name:     "ratelimit header",
+			status:   429,
+			header:   map[string]string{httpsling.HeaderRateLimit: "limit=10, remaining=0, reset=7"},
+			expected: 7 * time.Second,
+			ok:       true,
+		},
+		{
+			name:     "ratelimit structured field",
+			status:   503,
+			header:   map[string]string{httpsling.HeaderRateLimit: `"default";r=0;t=5`},
+			expected: 5 * time.Second,
+			ok:       true,
+		},
+		{
+			name:     "x-ratelimit-reset timestamp",
+			status:   503,
+			header:   map[string]string{httpsling.HeaderXRateLimitRemaining: "0", httpsling.HeaderXRateLimitReset: strconv.FormatInt(future.Unix(), 10)},
+			expected: time.Hour,
+			ok:       true,
+		},
+		{
+			name:   "reset ignored with remaining requests",
+			status: 503,
+			header: map[string]string{httpsling.HeaderXRateLimitRemaining: "4", httpsling.HeaderXRateLimitReset: "10"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: test.status, Header: http.Header{}}
+			for key, value := range test.header {
+				resp.Header.Set(key, value)
+			}
+
+			d, ok := httpsling.RetryAfter(resp)
+			assert.Equal(t, test.ok, ok)
+			assert.InDelta(t, test.expected, d, float64(2*time.Second))
+		})
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	var attempts int
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts++
+
+		w.Header().Set(httpsling.HeaderRetryAfter, "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	// the wait requested by the server is capped by MaxRetryAfter
+	r := httptestutil.Requester(s, httpsling.Retry(&httpsling.RetryConfig{
+		MaxAttempts:   2,
+		Backoff:       httpsling.ConstantBackoff(time.Hour),
+		MaxRetryAfter: 50 * time.Millisecond,
+	}))
+
+	t0 := time.Now()
+	resp, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, 2, attempts)
+	assert.InDelta(t, 50*time.Millisecond, time.Since(t0), float64(40*time.Millisecond))
+
+	// a negative MaxRetryAfter ignores the header
+	r = httptestutil.Requester(s, httpsling.Retry(&httpsling.RetryConfig{
+		MaxAttempts:   2,
+		Backoff:       httpsling.ConstantBackoff(10 * time.Millisecond),
+		MaxRetryAfter: -1,
+	}))
+
+	t0 = time.Now()
+	resp, err = r.Receive(nil)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Less(t, time.Since(t0), time.Second)
+}
+
+func TestRetryResponseBackoffer(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(429, httpsling.Header(httpsling.HeaderRetryAfter, "3600")))
+	defer s.Close()
+
+	var statuses []int
+
+	r := httptestutil.Requester(s, httpsling.Retry(&httpsling.RetryConfig{
+		MaxAttempts: 3,
+		Backoff: httpsling.ResponseBackofferFunc(func(attempt int, req *http.Request, resp *http.Response, err error) time.Duration {
+			require.NoError(t, err)
+			require.NotNil(t, req)
+
+			statuses = append(statuses, resp.StatusCode)
+
+			return time.Millisecond
+		}),
+	}))
+
+	resp, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, []int{429, 429}, statuses)
+}
+
+func TestRetryMaxElapsedTime(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(500))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Retry(&httpsling.RetryConfig{
+		MaxAttempts:    10,
+		Backoff:        httpsling.ConstantBackoff(40 * time.Millisecond),
+		MaxElapsedTime: 100 * time.Millisecond,
+	}))
+
+	i := httptestutil.Inspect(s)
+
+	t0 := time.Now()
+
+	resp, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	// the third retry would end after the time budget is spent
+	assert.Len(t, i.Drain(), 3)
+	assert.Equal(t, 3, httpsling.RetryAttempts(resp))
+	assert.Less(t, time.Since(t0), 150*time.Millisecond)
+}
+
+func TestRetryFinalAttempt(t *testing.T) {
+	var checked []int
+
+	body := io.NopCloser(strings.NewReader("done"))
+
+	doer := httpsling.Retry(&httpsling.RetryConfig{
+		MaxAttempts: 2,
+		Backoff:     httpsling.NoBackoff(),
+		ShouldRetry: httpsling.ShouldRetryerFunc(func(attempt int, _ *http.Request, _ *http.Response, _ error) bool {
+			checked = append(checked, attempt)
+
+			return true
+		}),
+	})(httpsling.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: body}, nil
+	}))
+
+	resp, err := doer.Do(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	// ShouldRetry isn't asked about the final attempt, and without a time budget the body is returned as it is
+	assert.Equal(t, []int{1}, checked)
+	assert.Equal(t, body, resp.Body)
+}
+
+func TestRetryMaxElapsedTimeSlowAttempt(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Retry(&httpsling.RetryConfig{
+		MaxAttempts:    10,
+		Backoff:        httpsling.NoBackoff(),
+		MaxElapsedTime: 100 * time.Millisecond,
+	}))
+
+	t0 := time.Now()
+
+	_, err := r.Receive(nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the attempt in flight is canceled when the time budget is spent
+	assert.Less(t, time.Since(t0), 500*time.Millisecond)
+}
+
+func TestRetryBudget(t *testing.T) {
+	var fail atomic.Bool
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer s.Close()
+
+	// the budget is shared by both requesters
+	budget := httpsling.NewRetryBudget(0.5, 2)
+	config := &httpsling.RetryConfig{
+		MaxAttempts: 3,
+		Backoff:     httpsling.NoBackoff(),
+		Budget:      budget,
+	}
+
+	r1 := httptestutil.Requester(s, httpsling.Retry(config))
+	r2 := httptestutil.Requester(s, httpsling.Retry(config))
+
+	i := httptestutil.Inspect(s)
+
+	fail.Store(true)
+
+	// the full budget allows two retries
+	resp, err := r1.Receive(nil)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Len(t, i.Drain(), 3)
+	assert.Zero(t, budget.Tokens())
+
+	// the exhausted budget allows no retries
+	resp, err = r2.Receive(nil)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Len(t, i.Drain(), 1)
+
+	// successful requests earn tokens back
+	fail.Store(false)
+
+	for range 2 {
+		resp, err = r2.Receive(nil)
+		require.NoError(t, err)
+
+		resp.Body.Close()
+	}
+
+	i.Drain()
+	assert.InDelta(t, 1, budget.Tokens(), 0.001)
+
+	fail.Store(true)
+
+	resp, err = r1.Receive(nil)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Len(t, i.Drain(), 2)
+	assert.Equal(t, 2, httpsling.RetryAttempts(resp))
+}
+
+func TestRetryAttempts(t *testing.T) {
+	var attempts []int
+
+	r := httpsling.MustNew(
+		httpsling.Retry(&httpsling.RetryConfig{MaxAttempts: 3, Backoff: httpsling.NoBackoff()}),
+		httpsling.Middleware(func(next httpsling.Doer) httpsling.Doer {
+			return httpsling.DoerFunc(func(req *http.Request) (*http.Response, error) {
+				attempts = append(attempts, httpsling.RetryAttempt(req.Context()))
+
+				return next.Do(req)
+			})
+		}),
+		httpsling.WithDoer(httpsling.DoerFunc(func(_ *http.Request) (*http.Response, error) {
+			return nil, syscall.ECONNRESET
+		})),
+	)
+
+	_, resp, err := httpsling.ReceiveAs[string](context.Background(), r)
+	require.Error(t, err)
+
+	var retryErr *httpsling.RetryError
+
+	require.ErrorAs(t, err, &retryErr)
+	assert.Equal(t, 3, retryErr.Attempts)
+	assert.ErrorIs(t, err, syscall.ECONNRESET)
+	assert.Contains(t, err.Error(), "(after 3 attempts)")
+	assert.Equal(t, []int{1, 2, 3}, attempts)
+	assert.Zero(t, resp.Attempts)
+
+	s := httptest.NewServer(httpsling.MockHandler(200))
+	defer s.Close()
+
+	_, resp, err = httpsling.ReceiveAs[string](context.Background(), httptestutil.Requester(s, httpsling.Retry(nil)))
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Attempts)
+}
+
+func TestRetryHooks(t *testing.T) {
+	var (
+		calls    int
+		retried  []httpsling.RetryAttemptInfo
+		gaveUp   []httpsling.RetryAttemptInfo
+		bodies   []string
+		attempts = []error{syscall.ECONNRESET, nil, syscall.EPIPE}
+	)
+
+	r := httpsling.MustNew(
+		httpsling.Retry(&httpsling.RetryConfig{
+			MaxAttempts: 3,
+			Backoff:     httpsling.ConstantBackoff(time.Millisecond),
+			OnRetry: func(info httpsling.RetryAttemptInfo) {
+				retried = append(retried, info)
+
+				// the body of the failed response can be read before it is drained
+				if info.Response != nil {
+					b, err := io.ReadAll(info.Response.Body)
+					require.NoError(t, err)
+
+					bodies = append(bodies, string(b))
+				}
+			},
+			OnGiveUp: func(info httpsling.RetryAttemptInfo) {
+				gaveUp = append(gaveUp, info)
+			},
+		}),
+		httpsling.WithDoer(httpsling.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			err := attempts[calls]
+			calls++
+
+			if err != nil {
+				return nil, err
+			}
+
+			resp := httpsling.MockResponse(http.StatusServiceUnavailable, httpsling.Body("try again"))
+			resp.Request = req
+
+			return resp, nil
+		})),
+	)
+
+	resp, err := r.Receive(nil) // nolint: bodyclose
+	require.Error(t, err)
+	assert.Nil(t, resp)
+
+	require.Len(t, retried, 2)
+	assert.Equal(t, 1, retried[0].Attempt)
+	assert.ErrorIs(t, retried[0].Err, syscall.ECONNRESET)
+	assert.Equal(t, time.Millisecond, retried[0].Delay)
+	assert.NotNil(t, retried[0].Request)
+	assert.Equal(t, 2, retried[1].Attempt)
+	assert.Equal(t, http.StatusServiceUnavailable, retried[1].StatusCode())
+	assert.Equal(t, []string{"try again"}, bodies)
+
+	require.Len(t, gaveUp, 1)
+	assert.Equal(t, 3, gaveUp[0].Attempt)
+	assert.ErrorIs(t, gaveUp[0].Err, syscall.EPIPE)
+	assert.Zero(t, gaveUp[0].Delay)
+
+	var retryErr *httpsling.RetryError
+	require.ErrorAs(t, err, &retryErr)
+	require.Len(t, retryErr.History, 3)
+	assert.ErrorIs(t, retryErr.History[0].Err, syscall.ECONNRESET)
+	assert.Equal(t, http.StatusServiceUnavailable, retryErr.History[1].StatusCode())
+	assert.Zero(t, retryErr.History[2].StatusCode())
+	assert.ErrorIs(t, err, syscall.EPIPE)
+}
+
+func TestRetryGiveUpOnBudget(t *testing.T) {
+	var gaveUp []int
+
+	s := httptest.NewServer(httpsling.MockHandler(http.StatusServiceUnavailable))
+	defer s.Close()
+
+	r := httptestutil.Requester(s, httpsling.Retry(&httpsling.RetryConfig{
+		MaxAttempts: 3,
+		Backoff:     httpsling.NoBackoff(),
+		Budget:      httpsling.NewRetryBudget(0.1, 1),
+		OnGiveUp: func(info httpsling.RetryAttemptInfo) {
+			gaveUp = append(gaveUp, info.Attempt)
+		},
+	}))
+
+	resp, err := r.Receive(nil)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, []int{2}, gaveUp)
+}
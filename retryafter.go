@@ -0,0 +1,132 @@
+package httpsling
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unixTimeThreshold distinguishes rate limit reset headers sent as unix timestamps from those sent as delta-seconds
+const unixTimeThreshold = 1_000_000_000
+
+// RetryAfter returns how long the server asked the client to wait before retrying; the Retry-After header is
+// consulted first, in either its delta-seconds or HTTP-date form. If the response was rate limited (a 429 status
+// code, or no remaining requests), the RateLimit, RateLimit-Reset and X-RateLimit-Reset headers are used next
+func RetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	if d, ok := parseRetryAfter(resp.Header.Get(HeaderRetryAfter)); ok {
+		return d, true
+	}
+
+	if !rateLimited(resp) {
+		return 0, false
+	}
+
+	return rateLimitReset(resp)
+}
+
+// rateLimitReset returns the time until the rate limit window resets, from the RateLimit, RateLimit-Reset or
+// X-RateLimit-Reset headers
+func rateLimitReset(resp *http.Response) (time.Duration, bool) {
+	if d, ok := parseDeltaSeconds(structuredParam(resp.Header.Get(HeaderRateLimit), "reset", "t")); ok {
+		return d, true
+	}
+
+	if d, ok := parseDeltaSeconds(resp.Header.Get(HeaderRateLimitReset)); ok {
+		return d, true
+	}
+
+	return parseResetTime(resp.Header.Get(HeaderXRateLimitReset))
+}
+
+// rateLimitRemaining returns the number of requests remaining in the rate limit window, from the RateLimit,
+// RateLimit-Remaining or X-RateLimit-Remaining headers
+func rateLimitRemaining(resp *http.Response) (int64, bool) {
+	for _, v := range []string{
+		structuredParam(resp.Header.Get(HeaderRateLimit), "remaining", "r"),
+		resp.Header.Get(HeaderRateLimitRemaining),
+		resp.Header.Get(HeaderXRateLimitRemaining),
+	} {
+		if remaining, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil && remaining >= 0 {
+			return remaining, true
+		}
+	}
+
+	return 0, false
+}
+
+// parseRetryAfter parses a Retry-After header value in delta-seconds or HTTP-date form
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if d, ok := parseDeltaSeconds(v); ok {
+		return d, true
+	}
+
+	t, err := http.ParseTime(strings.TrimSpace(v))
+	if err != nil {
+		return 0, false
+	}
+
+	return max(time.Until(t), 0), true
+}
+
+// parseDeltaSeconds parses a non-negative number of seconds
+func parseDeltaSeconds(v string) (time.Duration, bool) {
+	secs, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+
+	return time.Duration(secs) * time.Second, true
+}
+
+// parseResetTime parses a reset header which may be a unix timestamp or delta-seconds
+func parseResetTime(v string) (time.Duration, bool) {
+	secs, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+
+	if secs < unixTimeThreshold {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	return max(time.Until(time.Unix(secs, 0)), 0), true
+}
+
+// rateLimited returns true if the response indicates the client has no remaining requests
+func rateLimited(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	remaining, ok := rateLimitRemaining(resp)
+
+	return ok && remaining == 0
+}
+
+// structuredParam returns the value of the first of the named parameters found in a RateLimit header, which can be
+// in the "limit=10, remaining=0, reset=30" form or the structured field form `"default";r=0;t=30`
+func structuredParam(header string, names ...string) string {
+	if header == "" {
+		return ""
+	}
+
+	for _, part := range strings.FieldsFunc(header, func(r rune) bool { return r == ',' || r == ';' }) {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+
+		for _, name := range names {
+			if strings.EqualFold(key, name) {
+				return strings.Trim(value, `"`)
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,65 @@
+package httpsling
+
+import (
+	"sync"
+)
+
+// RetryBudget is a token bucket limiting retries to a ratio of successful requests; a single budget can be shared
+// by the Retry middleware of many Requesters, so that during an outage clients stop amplifying the load on the
+// upstream with retries. The zero value allows no retries
+type RetryBudget struct {
+	// Ratio is the number of tokens earned by each successful request, e.g. 0.1 allows retries for 10% of requests
+	Ratio float64
+	// MaxTokens is the maximum number of tokens the budget can hold, which bounds bursts of retries
+	MaxTokens float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// NewRetryBudget returns a full RetryBudget which earns ratio tokens per successful request, holding at most maxTokens
+func NewRetryBudget(ratio float64, maxTokens int) *RetryBudget {
+	return &RetryBudget{
+		Ratio:     ratio,
+		MaxTokens: float64(maxTokens),
+		tokens:    float64(maxTokens),
+	}
+}
+
+// Tokens returns the number of tokens currently in the budget
+func (b *RetryBudget) Tokens() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.tokens
+}
+
+// success deposits Ratio tokens for a successful request
+func (b *RetryBudget) success() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.tokens+b.Ratio, b.MaxTokens)
+}
+
+// withdraw takes a token for a retry, returning false if the budget is exhausted; a nil budget allows every retry
+func (b *RetryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
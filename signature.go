@@ -0,0 +1,875 @@
+package httpsling
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP message signature algorithms (RFC 9421 section 6.2)
+const (
+	AlgorithmHMACSHA256      = "hmac-sha256"
+	AlgorithmEd25519         = "ed25519"
+	AlgorithmECDSAP256SHA256 = "ecdsa-p256-sha256"
+	AlgorithmECDSAP384SHA384 = "ecdsa-p384-sha384"
+	AlgorithmRSAPSSSHA512    = "rsa-pss-sha512"
+)
+
+// DefaultSignatureLabel is the label signatures are added under when none is configured
+const DefaultSignatureLabel = "sig1"
+
+// DefaultSignatureComponents are the components covered by signatures when none are configured; header fields
+// missing from a request are left out of its signature, except content-digest, which is computed for requests with
+// a body
+var DefaultSignatureComponents = []string{"@method", "@target-uri", "content-digest", "content-type"}
+
+// DefaultMaxSignedBodySize is the default size above which request bodies checked against their Content-Digest
+// fail verification
+const DefaultMaxSignedBodySize = 10 << 20
+
+// SignatureKey is a key used to create or verify HTTP message signatures
+type SignatureKey struct {
+	// ID is sent as the keyid signature parameter, so the verifier can look up the key
+	ID string
+	// Key is a []byte shared secret for HMAC, an ed25519.PrivateKey, *ecdsa.PrivateKey or *rsa.PrivateKey to
+	// sign, or one of those or the matching public key to verify
+	Key any
+}
+
+// Algorithm returns the signature algorithm used with the key
+func (k SignatureKey) Algorithm() (string, error) {
+	switch key := k.Key.(type) {
+	case []byte:
+		return AlgorithmHMACSHA256, nil
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return AlgorithmEd25519, nil
+	case *ecdsa.PrivateKey:
+		return ecdsaAlgorithm(key.Curve)
+	case *ecdsa.PublicKey:
+		return ecdsaAlgorithm(key.Curve)
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return AlgorithmRSAPSSSHA512, nil
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnsupportedSignatureKey, k.Key)
+	}
+}
+
+func ecdsaAlgorithm(curve elliptic.Curve) (string, error) {
+	switch curve {
+	case elliptic.P256():
+		return AlgorithmECDSAP256SHA256, nil
+	case elliptic.P384():
+		return AlgorithmECDSAP384SHA384, nil
+	default:
+		return "", fmt.Errorf("%w: ecdsa curve %s", ErrUnsupportedSignatureKey, curve.Params().Name)
+	}
+}
+
+// ecdsaHash returns the hash and the size of the signature scalars used with the curve
+func ecdsaHash(curve elliptic.Curve, base []byte) ([]byte, int) {
+	if curve == elliptic.P384() {
+		sum := sha512.Sum384(base)
+
+		return sum[:], 48 // nolint: mnd
+	}
+
+	sum := sha256.Sum256(base)
+
+	return sum[:], 32 // nolint: mnd
+}
+
+// rsaPSSOptions are the RSASSA-PSS parameters of rsa-pss-sha512
+var rsaPSSOptions = &rsa.PSSOptions{SaltLength: 64, Hash: crypto.SHA512} // nolint: mnd
+
+// sign signs the signature base
+func (k SignatureKey) sign(base []byte) ([]byte, error) {
+	switch key := k.Key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(base)
+
+		return mac.Sum(nil), nil
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, base), nil
+	case *ecdsa.PrivateKey:
+		digest, size := ecdsaHash(key.Curve, base)
+
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		// the signature is the concatenation of r and s rather than ASN.1
+		sig := make([]byte, 2*size) // nolint: mnd
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+
+		return sig, nil
+	case *rsa.PrivateKey:
+		digest := sha512.Sum512(base)
+
+		return rsa.SignPSS(rand.Reader, key, crypto.SHA512, digest[:], rsaPSSOptions)
+	default:
+		return nil, fmt.Errorf("%w: %T can't sign", ErrUnsupportedSignatureKey, k.Key)
+	}
+}
+
+// verify checks the signature of the signature base
+func (k SignatureKey) verify(base, sig []byte) error {
+	var ok bool
+
+	switch key := k.Key.(type) {
+	case []byte:
+		expected, _ := k.sign(base)
+		ok = hmac.Equal(expected, sig)
+	case ed25519.PrivateKey:
+		ok = ed25519.Verify(key.Public().(ed25519.PublicKey), base, sig)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, base, sig)
+	case *ecdsa.PrivateKey:
+		ok = verifyECDSA(&key.PublicKey, base, sig)
+	case *ecdsa.PublicKey:
+		ok = verifyECDSA(key, base, sig)
+	case *rsa.PrivateKey:
+		ok = verifyRSAPSS(&key.PublicKey, base, sig)
+	case *rsa.PublicKey:
+		ok = verifyRSAPSS(key, base, sig)
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedSignatureKey, k.Key)
+	}
+
+	if !ok {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+
+	return nil
+}
+
+func verifyECDSA(key *ecdsa.PublicKey, base, sig []byte) bool {
+	digest, size := ecdsaHash(key.Curve, base)
+	if len(sig) != 2*size { // nolint: mnd
+		return false
+	}
+
+	return ecdsa.Verify(key, digest, new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:]))
+}
+
+func verifyRSAPSS(key *rsa.PublicKey, base, sig []byte) bool {
+	digest := sha512.Sum512(base)
+
+	return rsa.VerifyPSS(key, crypto.SHA512, digest[:], sig, rsaPSSOptions) == nil
+}
+
+// SignatureConfig defines settings for the SignRequests middleware
+type SignatureConfig struct {
+	// Key signs the requests
+	Key SignatureKey
+	// Label is the label of the signature in the Signature-Input and Signature headers (default DefaultSignatureLabel)
+	Label string
+	// Components are the covered components (default DefaultSignatureComponents): derived components such as
+	// @method, @target-uri, @authority, @scheme, @path, @query and @request-target, and lower case header field names
+	Components []string
+	// Expires, if set, limits how long the signature is valid for
+	Expires time.Duration
+	// Nonce adds a random nonce to each signature, for verifiers which detect replays
+	Nonce bool
+	// Tag is an application specific tag added to each signature
+	Tag string
+}
+
+func (c *SignatureConfig) normalize() {
+	if c.Label == "" {
+		c.Label = DefaultSignatureLabel
+	}
+
+	if len(c.Components) == 0 {
+		c.Components = DefaultSignatureComponents
+	}
+}
+
+// SignRequests signs requests with an HTTP message signature (RFC 9421), adding the Signature-Input and
+// Signature headers. Install it after any middleware which changes the covered components; header fields the
+// request doesn't have are left out of the signature, and a Content-Digest is added to requests with a body when
+// content-digest is covered
+func SignRequests(config *SignatureConfig) Middleware {
+	c := SignatureConfig{}
+	if config != nil {
+		c = *config
+	}
+
+	c.normalize()
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			signed := *req
+			signed.Header = req.Header.Clone()
+
+			if err := signRequest(&signed, &c, time.Now()); err != nil {
+				return nil, err
+			}
+
+			return next.Do(&signed)
+		})
+	}
+}
+
+// signRequest adds the signature headers to the request
+func signRequest(req *http.Request, c *SignatureConfig, created time.Time) error {
+	alg, err := c.Key.Algorithm()
+	if err != nil {
+		return err
+	}
+
+	if err := addContentDigest(req, c.Components); err != nil {
+		return err
+	}
+
+	components := make([]string, 0, len(c.Components))
+
+	for _, component := range c.Components {
+		component = strings.ToLower(component)
+		if strings.HasPrefix(component, "@") || len(req.Header.Values(component)) > 0 {
+			components = append(components, component)
+		}
+	}
+
+	params := signatureParams{components: components, created: created.Unix(), alg: alg, keyID: c.Key.ID, tag: c.Tag}
+
+	if c.Expires > 0 {
+		params.expires = created.Add(c.Expires).Unix()
+	}
+
+	if c.Nonce {
+		b := make([]byte, 16) // nolint: mnd
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+
+		params.nonce = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	serialized, err := params.serialize()
+	if err != nil {
+		return err
+	}
+
+	base, err := signatureBase(req, components, serialized)
+	if err != nil {
+		return err
+	}
+
+	sig, err := c.Key.sign(base)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(HeaderSignatureInput, c.Label+"="+serialized)
+	req.Header.Set(HeaderSignature, c.Label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+
+	return nil
+}
+
+// addContentDigest computes the Content-Digest of a request with a body if the signature covers it, so the
+// signature covers the body
+func addContentDigest(req *http.Request, components []string) error {
+	if req.Header.Get(HeaderContentDigest) != "" || !slices.ContainsFunc(components, func(component string) bool {
+		return strings.EqualFold(component, "content-digest")
+	}) {
+		return nil
+	}
+
+	body, peeked, err := peekRequestBody(req, -1)
+	if err != nil {
+		return err
+	}
+
+	req.Body = peeked.Body
+
+	if len(body) > 0 {
+		value, err := Digest(body)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set(HeaderContentDigest, value)
+	}
+
+	return nil
+}
+
+// signatureParams are the parameters of a signature
+type signatureParams struct {
+	components []string
+	created    int64
+	expires    int64
+	nonce      string
+	alg        string
+	keyID      string
+	tag        string
+}
+
+// serialize serializes the parameters as the value of the @signature-params component
+func (p signatureParams) serialize() (string, error) {
+	var sb strings.Builder
+
+	sb.WriteString("(")
+
+	for i, component := range p.components {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+
+		quoted, err := quoteString(component)
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(quoted)
+	}
+
+	sb.WriteString(")")
+
+	if p.created != 0 {
+		sb.WriteString(";created=" + strconv.FormatInt(p.created, 10))
+	}
+
+	if p.expires != 0 {
+		sb.WriteString(";expires=" + strconv.FormatInt(p.expires, 10))
+	}
+
+	for _, param := range [][2]string{{"nonce", p.nonce}, {"alg", p.alg}, {"keyid", p.keyID}, {"tag", p.tag}} {
+		if param[1] == "" {
+			continue
+		}
+
+		quoted, err := quoteString(param[1])
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(";" + param[0] + "=" + quoted)
+	}
+
+	return sb.String(), nil
+}
+
+// signatureBase builds the signature base of the request (RFC 9421 section 2.5)
+func signatureBase(req *http.Request, components []string, serializedParams string) ([]byte, error) {
+	var sb strings.Builder
+
+	for _, component := range components {
+		value, err := componentValue(req, component)
+		if err != nil {
+			return nil, err
+		}
+
+		quoted, err := quoteString(component)
+		if err != nil {
+			return nil, err
+		}
+
+		sb.WriteString(quoted + ": " + value + "\n")
+	}
+
+	sb.WriteString(`"@signature-params": ` + serializedParams)
+
+	return []byte(sb.String()), nil
+}
+
+// componentValue returns the value of a covered component of the request
+func componentValue(req *http.Request, component string) (string, error) {
+	scheme := strings.ToLower(req.URL.Scheme)
+	if scheme == "" {
+		scheme = "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+	}
+
+	authority := req.Host
+	if authority == "" {
+		authority = req.URL.Host
+	}
+
+	authority = strings.ToLower(authority)
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	query := "?" + req.URL.RawQuery
+
+	switch component {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		if req.URL.RawQuery == "" {
+			return scheme + "://" + authority + path, nil
+		}
+
+		return scheme + "://" + authority + path + query, nil
+	case "@authority":
+		return authority, nil
+	case "@scheme":
+		return scheme, nil
+	case "@path":
+		return path, nil
+	case "@query":
+		return query, nil
+	case "@request-target":
+		if req.URL.RawQuery == "" {
+			return path, nil
+		}
+
+		return path + query, nil
+	}
+
+	if strings.HasPrefix(component, "@") {
+		return "", fmt.Errorf("%w: unsupported component %s", ErrInvalidSignature, component)
+	}
+
+	// the values are trimmed in a copy, as Values returns the slice held by the header
+	values := slices.Clone(req.Header.Values(component))
+	if len(values) == 0 {
+		return "", fmt.Errorf("%w: covered header %s is missing", ErrInvalidSignature, component)
+	}
+
+	for i, v := range values {
+		values[i] = strings.TrimSpace(v)
+	}
+
+	return strings.Join(values, ", "), nil
+}
+
+// SignatureKeyResolver returns the key of a keyid
+type SignatureKeyResolver func(ctx context.Context, keyID string) (SignatureKey, error)
+
+// SignatureKeys returns a SignatureKeyResolver looking keys up by their ID
+func SignatureKeys(keys ...SignatureKey) SignatureKeyResolver {
+	byID := make(map[string]SignatureKey, len(keys))
+	for _, key := range keys {
+		byID[key.ID] = key
+	}
+
+	return func(_ context.Context, keyID string) (SignatureKey, error) {
+		key, ok := byID[keyID]
+		if !ok {
+			return SignatureKey{}, fmt.Errorf("%w: unknown keyid %q", ErrInvalidSignature, keyID)
+		}
+
+		return key, nil
+	}
+}
+
+// VerifierConfig defines settings for verifying HTTP message signatures
+type VerifierConfig struct {
+	// Keys looks up the key of the keyid of a signature
+	Keys SignatureKeyResolver
+	// Label, if set, only verifies the signature with that label; otherwise a single valid signature is enough
+	Label string
+	// RequiredComponents must be covered by the signature (default @method and @target-uri)
+	RequiredComponents []string
+	// MaxAge is the maximum age of a signature, based on its created parameter (default 5 minutes)
+	MaxAge time.Duration
+	// ClockSkew is the tolerated difference between the clocks of the signer and the verifier (default 1 minute)
+	ClockSkew time.Duration
+	// MaxBodySize is the size above which a body checked against its Content-Digest fails verification, rather than
+	// being read in full (default DefaultMaxSignedBodySize)
+	MaxBodySize int64
+	// ErrResponseHandler writes the response for requests which fail verification (default a 401 problem document)
+	ErrResponseHandler ErrResponseHandler
+}
+
+func (c *VerifierConfig) normalize() {
+	if len(c.RequiredComponents) == 0 {
+		c.RequiredComponents = []string{"@method", "@target-uri"}
+	}
+
+	if c.MaxAge <= 0 {
+		c.MaxAge = 5 * time.Minute // nolint: mnd
+	}
+
+	if c.ClockSkew <= 0 {
+		c.ClockSkew = time.Minute
+	}
+
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = DefaultMaxSignedBodySize
+	}
+
+	if c.ErrResponseHandler == nil {
+		c.ErrResponseHandler = func(err error) http.HandlerFunc {
+			return NewProblem(http.StatusUnauthorized, err.Error()).ServeHTTP
+		}
+	}
+}
+
+// VerifySignatures returns http middleware which only lets requests with a valid HTTP message signature through
+func VerifySignatures(config *VerifierConfig) func(http.Handler) http.Handler {
+	c := VerifierConfig{}
+	if config != nil {
+		c = *config
+	}
+
+	c.normalize()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if err := VerifyRequest(req, &c); err != nil {
+				c.ErrResponseHandler(err).ServeHTTP(w, req)
+
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// VerifyRequest verifies the HTTP message signatures of a request received by a server, returning an error wrapping
+// ErrMissingSignature or ErrInvalidSignature if there is no valid signature. When a signature covers content-digest,
+// the body, of at most MaxBodySize bytes, is read and checked against the Content-Digest header, then replaced so
+// handlers can still read it
+func VerifyRequest(req *http.Request, config *VerifierConfig) error {
+	c := VerifierConfig{}
+	if config != nil {
+		c = *config
+	}
+
+	c.normalize()
+
+	if c.Keys == nil {
+		return fmt.Errorf("%w: no keys configured", ErrInvalidSignature)
+	}
+
+	inputs := parseDictionary(req.Header.Values(HeaderSignatureInput))
+	signatures := parseDictionary(req.Header.Values(HeaderSignature))
+
+	if len(inputs) == 0 || len(signatures) == 0 {
+		return ErrMissingSignature
+	}
+
+	var errs []error
+
+	for _, label := range sortedKeys(inputs) {
+		if c.Label != "" && label != c.Label {
+			continue
+		}
+
+		err := verifySignature(req, &c, inputs[label], signatures[label])
+		if err == nil {
+			return nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", label, err))
+	}
+
+	if len(errs) == 0 {
+		return fmt.Errorf("%w: no signature labeled %s", ErrMissingSignature, c.Label)
+	}
+
+	return errors.Join(errs...)
+}
+
+// verifySignature verifies a single signature
+func verifySignature(req *http.Request, c *VerifierConfig, input, signature string) error {
+	params, err := parseSignatureParams(input)
+	if err != nil {
+		return err
+	}
+
+	sigB64, ok := strings.CutPrefix(signature, ":")
+	sigB64, ok2 := strings.CutSuffix(sigB64, ":")
+
+	if !ok || !ok2 {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(sigB64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature: %w", ErrInvalidSignature, err)
+	}
+
+	for _, required := range c.RequiredComponents {
+		if !slices.Contains(params.components, strings.ToLower(required)) {
+			return fmt.Errorf("%w: %s is not covered", ErrInvalidSignature, required)
+		}
+	}
+
+	now := time.Now()
+
+	if params.created == 0 {
+		return fmt.Errorf("%w: created parameter is missing", ErrInvalidSignature)
+	}
+
+	created := time.Unix(params.created, 0)
+	if created.After(now.Add(c.ClockSkew)) {
+		return fmt.Errorf("%w: created in the future", ErrInvalidSignature)
+	}
+
+	if now.Sub(created) > c.MaxAge+c.ClockSkew {
+		return fmt.Errorf("%w: created %s ago", ErrSignatureExpired, now.Sub(created).Round(time.Second))
+	}
+
+	if params.expires != 0 && now.After(time.Unix(params.expires, 0).Add(c.ClockSkew)) {
+		return fmt.Errorf("%w: expired at %s", ErrSignatureExpired, time.Unix(params.expires, 0).UTC())
+	}
+
+	key, err := c.Keys(req.Context(), params.keyID)
+	if err != nil {
+		return err
+	}
+
+	if params.alg != "" {
+		alg, err := key.Algorithm()
+		if err != nil {
+			return err
+		}
+
+		if alg != params.alg {
+			return fmt.Errorf("%w: algorithm %s doesn't match the key", ErrInvalidSignature, params.alg)
+		}
+	}
+
+	base, err := signatureBase(req, params.components, input)
+	if err != nil {
+		return err
+	}
+
+	if err := key.verify(base, sig); err != nil {
+		return err
+	}
+
+	if slices.Contains(params.components, "content-digest") {
+		return verifyContentDigest(req, c.MaxBodySize)
+	}
+
+	return nil
+}
+
+// verifyContentDigest checks the body of a request, of at most maxSize bytes, against its Content-Digest; the
+// signature only covers the header, so a request with a changed body would verify otherwise
+func verifyContentDigest(req *http.Request, maxSize int64) error {
+	digests := parseDigests(req.Header, HeaderContentDigest)
+	if len(digests) == 0 {
+		return fmt.Errorf("%w: content-digest has no supported digest", ErrInvalidSignature)
+	}
+
+	var body []byte
+
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(io.LimitReader(req.Body, maxSize+1))
+		req.Body.Close()
+
+		if err != nil {
+			return err
+		}
+
+		if int64(len(b)) > maxSize {
+			return fmt.Errorf("%w: body exceeds %d bytes", ErrInvalidSignature, maxSize)
+		}
+
+		body = b
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	for _, d := range digests {
+		d.hash.Write(body)
+
+		if err := d.verify(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+		}
+	}
+
+	return nil
+}
+
+// parseSignatureParams parses the inner list and parameters of a Signature-Input member
+func parseSignatureParams(input string) (signatureParams, error) {
+	var params signatureParams
+
+	list, rest, ok := strings.Cut(strings.TrimPrefix(input, "("), ")")
+	if !ok || !strings.HasPrefix(input, "(") {
+		return params, fmt.Errorf("%w: malformed signature input", ErrInvalidSignature)
+	}
+
+	for _, item := range strings.Fields(list) {
+		component, err := unquoteString(item)
+		if err != nil {
+			return params, fmt.Errorf("%w: unsupported component %s", ErrInvalidSignature, item)
+		}
+
+		params.components = append(params.components, component)
+	}
+
+	for _, param := range splitOutsideQuotes(rest, ';') {
+		name, value, _ := strings.Cut(param, "=")
+
+		var err error
+
+		if strings.HasPrefix(value, `"`) {
+			if value, err = unquoteString(value); err != nil {
+				return params, fmt.Errorf("%w: malformed %s parameter", ErrInvalidSignature, name)
+			}
+		}
+
+		switch name {
+		case "created":
+			params.created, err = strconv.ParseInt(value, 10, 64)
+		case "expires":
+			params.expires, err = strconv.ParseInt(value, 10, 64)
+		case "nonce":
+			params.nonce = value
+		case "alg":
+			params.alg = value
+		case "keyid":
+			params.keyID = value
+		case "tag":
+			params.tag = value
+		}
+
+		if err != nil {
+			return params, fmt.Errorf("%w: malformed %s parameter", ErrInvalidSignature, name)
+		}
+	}
+
+	return params, nil
+}
+
+// quoteString serializes s as a structured field string (RFC 8941 section 4.1.6), which may only hold printable
+// ASCII characters
+func quoteString(s string) (string, error) {
+	var sb strings.Builder
+
+	sb.WriteByte('"')
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e { // nolint: mnd
+			return "", fmt.Errorf("%w: %q is not a valid structured field string", ErrInvalidSignature, s)
+		}
+
+		if c == '"' || c == '\\' {
+			sb.WriteByte('\\')
+		}
+
+		sb.WriteByte(c)
+	}
+
+	sb.WriteByte('"')
+
+	return sb.String(), nil
+}
+
+// unquoteString parses a structured field string (RFC 8941 section 4.2.5), whose only escapes are \" and \\
+func unquoteString(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("%w: %s is not a structured field string", ErrInvalidSignature, s)
+	}
+
+	var sb strings.Builder
+
+	for i := 1; i < len(s)-1; i++ {
+		c := s[i]
+
+		switch {
+		case c == '\\':
+			i++
+			if i == len(s)-1 || s[i] != '"' && s[i] != '\\' {
+				return "", fmt.Errorf("%w: invalid escape in %s", ErrInvalidSignature, s)
+			}
+
+			c = s[i]
+		case c == '"', c < 0x20, c > 0x7e: // nolint: mnd
+			return "", fmt.Errorf("%w: invalid character in %s", ErrInvalidSignature, s)
+		}
+
+		sb.WriteByte(c)
+	}
+
+	return sb.String(), nil
+}
+
+// parseDictionary parses the members of a structured field dictionary into their raw values
+func parseDictionary(values []string) map[string]string {
+	members := map[string]string{}
+
+	for _, v := range values {
+		for _, member := range splitOutsideQuotes(v, ',') {
+			label, value, ok := strings.Cut(member, "=")
+			if ok {
+				members[label] = value
+			}
+		}
+	}
+
+	return members
+}
+
+// splitOutsideQuotes splits s at the separator where it is outside quoted strings and parentheses, trimming the
+// parts and dropping empty ones
+func splitOutsideQuotes(s string, sep byte) []string {
+	var (
+		parts    []string
+		quoted   bool
+		depth    int
+		start    int
+		appendAt = func(end int) {
+			if part := strings.TrimSpace(s[start:end]); part != "" {
+				parts = append(parts, part)
+			}
+		}
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			appendAt(i)
+			start = i + 1
+		}
+	}
+
+	appendAt(len(s))
+
+	return parts
+}
+
+// sortedKeys returns the keys of the map in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
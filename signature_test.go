@@ -0,0 +1,311 @@
+package httpsling
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureBaseHMACTestVector(t *testing.T) {
+	// RFC 9421 appendix B.2.5
+	secret, err := base64.StdEncoding.DecodeString("uzvJfB4u3N0Jy4T7NZ75MDVcr8zSTInedJtkgcu46YW4XByzNJjxBdtjUkdJPBtbmHhIDi6pcl8jsasjlTMtDQ==")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/foo?param=Value&Pet=dog", strings.NewReader(`{"hello": "world"}`))
+	req.Header.Set(HeaderDate, "Tue, 20 Apr 2021 02:07:55 GMT")
+	req.Header.Set(HeaderContentType, ContentTypeJSON)
+
+	key := SignatureKey{ID: "test-shared-secret", Key: secret}
+
+	params := signatureParams{components: []string{"date", "@authority", "content-type"}, created: 1618884473, keyID: key.ID}
+	serialized, err := params.serialize()
+	require.NoError(t, err)
+	assert.Equal(t, `("date" "@authority" "content-type");created=1618884473;keyid="test-shared-secret"`, serialized)
+
+	base, err := signatureBase(req, params.components, serialized)
+	require.NoError(t, err)
+
+	sig, err := key.sign(base)
+	require.NoError(t, err)
+	assert.Equal(t, "pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=", base64.StdEncoding.EncodeToString(sig))
+
+	parsed, err := parseSignatureParams(serialized)
+	require.NoError(t, err)
+	assert.Equal(t, params, parsed)
+}
+
+func signatureServer(t *testing.T, config *VerifierConfig) *httptest.Server {
+	t.Helper()
+
+	s := httptest.NewServer(VerifySignatures(config)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestSignaturesRoundTrip(t *testing.T) {
+	ecP256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	ecP384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		alg       string
+		signKey   any
+		verifyKey any
+	}{
+		{name: "hmac", alg: AlgorithmHMACSHA256, signKey: []byte("secret"), verifyKey: []byte("secret")},
+		{name: "ed25519", alg: AlgorithmEd25519, signKey: edPriv, verifyKey: edPub},
+		{name: "ecdsa p256", alg: AlgorithmECDSAP256SHA256, signKey: ecP256, verifyKey: &ecP256.PublicKey},
+		{name: "ecdsa p384", alg: AlgorithmECDSAP384SHA384, signKey: ecP384, verifyKey: &ecP384.PublicKey},
+		{name: "rsa pss", alg: AlgorithmRSAPSSSHA512, signKey: rsaKey, verifyKey: &rsaKey.PublicKey},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := signatureServer(t, &VerifierConfig{
+				Keys:               SignatureKeys(SignatureKey{ID: "key", Key: tc.verifyKey}),
+				RequiredComponents: []string{"@method", "@target-uri", "content-type"},
+			})
+
+			var input string
+
+			r, err := New(URL(s.URL), SignRequests(&SignatureConfig{Key: SignatureKey{ID: "key", Key: tc.signKey}, Nonce: true}),
+				Middleware(func(next Doer) Doer {
+					return DoerFunc(func(req *http.Request) (*http.Response, error) {
+						input = req.Header.Get(HeaderSignatureInput)
+
+						return next.Do(req)
+					})
+				}))
+			require.NoError(t, err)
+
+			resp, err := r.Send(Post("/things"), QueryParam("q", "1"), Body(map[string]string{"meow": "woof"}))
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+			assert.Contains(t, input, `alg="`+tc.alg+`"`)
+			assert.Contains(t, input, `("@method" "@target-uri" "content-digest" "content-type")`)
+		})
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	key := SignatureKey{ID: "key", Key: []byte("secret")}
+	config := &VerifierConfig{Keys: SignatureKeys(key)}
+
+	signed := func(t *testing.T, c *SignatureConfig, created time.Time) *http.Request {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/things?q=1", nil)
+		req.Header.Set(HeaderAccept, ContentTypeJSON)
+
+		if c.Key.Key == nil {
+			c.Key = key
+		}
+
+		c.normalize()
+
+		require.NoError(t, signRequest(req, c, created))
+
+		return req
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		req := signed(t, &SignatureConfig{Components: []string{"@method", "@target-uri", "accept"}}, time.Now())
+		assert.NoError(t, VerifyRequest(req, config))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/things", nil)
+		assert.ErrorIs(t, VerifyRequest(req, config), ErrMissingSignature)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		req := signed(t, &SignatureConfig{}, time.Now())
+		req.Method = http.MethodDelete
+
+		assert.ErrorIs(t, VerifyRequest(req, config), ErrInvalidSignature)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		req := signed(t, &SignatureConfig{Key: SignatureKey{ID: "key", Key: []byte("other")}}, time.Now())
+		assert.ErrorIs(t, VerifyRequest(req, config), ErrInvalidSignature)
+	})
+
+	t.Run("unknown keyid", func(t *testing.T) {
+		req := signed(t, &SignatureConfig{Key: SignatureKey{ID: "other", Key: []byte("secret")}}, time.Now())
+		assert.ErrorIs(t, VerifyRequest(req, config), ErrInvalidSignature)
+	})
+
+	t.Run("required component not covered", func(t *testing.T) {
+		req := signed(t, &SignatureConfig{Components: []string{"@method"}}, time.Now())
+		assert.ErrorIs(t, VerifyRequest(req, config), ErrInvalidSignature)
+	})
+
+	t.Run("too old", func(t *testing.T) {
+		req := signed(t, &SignatureConfig{}, time.Now().Add(-10*time.Minute))
+		assert.ErrorIs(t, VerifyRequest(req, config), ErrSignatureExpired)
+	})
+
+	t.Run("within clock skew", func(t *testing.T) {
+		req := signed(t, &SignatureConfig{}, time.Now().Add(30*time.Second))
+		assert.NoError(t, VerifyRequest(req, config))
+	})
+
+	t.Run("from the future", func(t *testing.T) {
+		req := signed(t, &SignatureConfig{}, time.Now().Add(5*time.Minute))
+		assert.ErrorIs(t, VerifyRequest(req, config), ErrInvalidSignature)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		req := signed(t, &SignatureConfig{Expires: time.Second}, time.Now().Add(-2*time.Minute))
+		assert.ErrorIs(t, VerifyRequest(req, config), ErrSignatureExpired)
+	})
+
+	t.Run("label", func(t *testing.T) {
+		req := signed(t, &SignatureConfig{Label: "other"}, time.Now())
+		assert.ErrorIs(t, VerifyRequest(req, &VerifierConfig{Keys: SignatureKeys(key), Label: "sig1"}), ErrMissingSignature)
+		assert.NoError(t, VerifyRequest(req, &VerifierConfig{Keys: SignatureKeys(key), Label: "other"}))
+	})
+}
+
+func TestSignedContentDigest(t *testing.T) {
+	key := SignatureKey{ID: "key", Key: []byte("secret")}
+	c := &SignatureConfig{Key: key}
+	c.normalize()
+
+	signed := func(t *testing.T) *http.Request {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/hooks", strings.NewReader(`{"amount":1}`))
+		req.Header.Set(HeaderContentType, ContentTypeJSON)
+		require.NoError(t, signRequest(req, c, time.Now()))
+
+		return req
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		req := signed(t)
+		assert.Equal(t, "sha-256=:"+base64.StdEncoding.EncodeToString(sha256Sum(`{"amount":1}`))+":", req.Header.Get(HeaderContentDigest))
+		assert.Contains(t, req.Header.Get(HeaderSignatureInput), `"content-digest"`)
+		require.NoError(t, VerifyRequest(req, &VerifierConfig{Keys: SignatureKeys(key)}))
+
+		// the body can still be read after verification
+		b, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"amount":1}`, string(b))
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		req := signed(t)
+		req.Body = io.NopCloser(strings.NewReader(`{"amount":1000}`))
+
+		err := VerifyRequest(req, &VerifierConfig{Keys: SignatureKeys(key)})
+		require.ErrorIs(t, err, ErrInvalidSignature)
+		assert.ErrorIs(t, err, ErrDigestMismatch)
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		req := signed(t)
+
+		err := VerifyRequest(req, &VerifierConfig{Keys: SignatureKeys(key), MaxBodySize: 4})
+		require.ErrorIs(t, err, ErrInvalidSignature)
+		assert.Contains(t, err.Error(), "body exceeds 4 bytes")
+	})
+}
+
+func sha256Sum(s string) []byte {
+	sum := sha256.Sum256([]byte(s))
+
+	return sum[:]
+}
+
+func TestVerifySignaturesRejects(t *testing.T) {
+	s := signatureServer(t, &VerifierConfig{Keys: SignatureKeys(SignatureKey{ID: "key", Key: []byte("secret")})})
+
+	resp, err := http.Get(s.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, ContentTypeProblemJSON, resp.Header.Get(HeaderContentType))
+}
+
+func TestComponentValueKeepsHeaders(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Add("X-Values", " a ")
+	req.Header.Add("X-Values", "b ")
+
+	value, err := componentValue(req, "x-values")
+	require.NoError(t, err)
+	assert.Equal(t, "a, b", value)
+
+	// the request headers are left untouched
+	assert.Equal(t, []string{" a ", "b "}, req.Header.Values("X-Values"))
+}
+
+func TestStructuredFieldStrings(t *testing.T) {
+	quoted, err := quoteString(`say "hi" \ bye`)
+	require.NoError(t, err)
+	assert.Equal(t, `"say \"hi\" \\ bye"`, quoted)
+
+	s, err := unquoteString(quoted)
+	require.NoError(t, err)
+	assert.Equal(t, `say "hi" \ bye`, s)
+
+	// only printable ASCII can be serialized
+	_, err = quoteString("caf\u00e9")
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = quoteString("a\tb")
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	// escapes other than \" and \\, unescaped quotes and non-ASCII are rejected
+	for _, invalid := range []string{`"\u00e9"`, `"\x41"`, `"\n"`, `"a"b"`, "\"caf\u00e9\"", `"a\"`, `a`} {
+		_, err := unquoteString(invalid)
+		require.ErrorIs(t, err, ErrInvalidSignature, invalid)
+	}
+
+	// signing fails rather than producing a signature other implementations can't verify
+	c := &SignatureConfig{Key: SignatureKey{ID: "cl\u00e9", Key: []byte("secret")}}
+	c.normalize()
+
+	err = signRequest(httptest.NewRequest(http.MethodGet, "http://example.com/", nil), c, time.Now())
+	require.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestVerifyRequestJoinsErrors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set(HeaderSignatureInput, `a=("@method");created=1, b=("@method");created=1`)
+	req.Header.Set(HeaderSignature, `a=:YQ==:, b=:Yg==:`)
+
+	err := VerifyRequest(req, &VerifierConfig{Keys: SignatureKeys(SignatureKey{ID: "key", Key: []byte("secret")})})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a: ")
+	assert.Contains(t, err.Error(), "b: ")
+}
@@ -0,0 +1,310 @@
+package httpsling
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage persists the contents of uploaded files
+type Storage interface {
+	// Put stores the contents of r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get returns the contents stored under key, or ErrFileNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// URL returns a URL which can be used to retrieve the object for the given duration
+	URL(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// LocalStorage is a Storage which keeps files in a directory on the local filesystem
+type LocalStorage struct {
+	// Dir is the directory the files are stored in
+	Dir string
+	// BaseURL is the URL the directory is served under; if empty, URL returns file:// URLs
+	BaseURL string
+	// SigningKey signs the URLs returned under BaseURL with HMAC-SHA256, so the handler serving them can check them
+	// with VerifyURL; without it the expires query parameter is not protected and anyone can change it
+	SigningKey []byte
+}
+
+// NewLocalStorage returns a LocalStorage which keeps files in dir, creating it if needed
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil { // nolint: mnd
+		return nil, fmt.Errorf("error creating storage directory: %w", err)
+	}
+
+	return &LocalStorage{Dir: dir}, nil
+}
+
+// path returns the location of key inside the storage directory; keys are cleaned so they
+// can't escape the directory
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if cleaned == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidStorageKey, key)
+	}
+
+	return filepath.Join(s.Dir, filepath.FromSlash(cleaned)), nil
+}
+
+// Put implements Storage
+func (s *LocalStorage) Put(_ context.Context, key string, r io.Reader, _ string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil { // nolint: mnd
+		return fmt.Errorf("error creating storage directory: %w", err)
+	}
+
+	f, err := os.Create(p)
+	if err != nil {
+		return fmt.Errorf("error creating file %s: %w", key, err)
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+
+		return fmt.Errorf("error writing file %s: %w", key, err)
+	}
+
+	return f.Close()
+}
+
+// Get implements Storage
+func (s *LocalStorage) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, key)
+	}
+
+	return f, err
+}
+
+// Delete implements Storage
+func (s *LocalStorage) Delete(_ context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting file %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// URL implements Storage; the local filesystem can't enforce expiry, so when BaseURL is set the
+// expiry time is passed along as an expires query parameter, signed if SigningKey is set, for the serving handler
+// to check with VerifyURL
+func (s *LocalStorage) URL(_ context.Context, key string, expires time.Duration) (string, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	if s.BaseURL == "" {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return "", err
+		}
+
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String(), nil
+	}
+
+	rel, err := filepath.Rel(s.Dir, p)
+	if err != nil {
+		return "", err
+	}
+
+	return storageURL(s.BaseURL, filepath.ToSlash(rel), expires, s.SigningKey)
+}
+
+// VerifyURL checks the signature and expiry time of a URL returned by URL, like the URL of a request to the handler
+// serving BaseURL, and returns the key of the object it refers to; it fails with ErrInvalidStorageURL
+func (s *LocalStorage) VerifyURL(u *url.URL) (string, error) {
+	if len(s.SigningKey) == 0 {
+		return "", fmt.Errorf("%w: no signing key is configured", ErrInvalidStorageURL)
+	}
+
+	base, err := url.Parse(s.BaseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base url: %w", err)
+	}
+
+	key, ok := strings.CutPrefix(u.Path, strings.TrimSuffix(base.Path, "/")+"/")
+	if !ok || key == "" {
+		return "", fmt.Errorf("%w: %s is not under %s", ErrInvalidStorageURL, u.Path, s.BaseURL)
+	}
+
+	q := u.Query()
+	expires := q.Get("expires")
+
+	signature, err := hex.DecodeString(q.Get("signature"))
+	if err != nil || !hmac.Equal(signature, signStorageURL(s.SigningKey, key, expires)) {
+		return "", fmt.Errorf("%w: invalid signature", ErrInvalidStorageURL)
+	}
+
+	if expires != "" {
+		unix, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().Unix() > unix {
+			return "", fmt.Errorf("%w: expired", ErrInvalidStorageURL)
+		}
+	}
+
+	return key, nil
+}
+
+// MemoryStorage is a Storage which keeps files in memory, intended for tests
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data        []byte
+	contentType string
+}
+
+// NewMemoryStorage returns an empty MemoryStorage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: map[string]memoryObject{}}
+}
+
+// Put implements Storage
+func (s *MemoryStorage) Put(_ context.Context, key string, r io.Reader, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.objects == nil {
+		s.objects = map[string]memoryObject{}
+	}
+
+	s.objects[key] = memoryObject{data: data, contentType: contentType}
+
+	return nil
+}
+
+// Get implements Storage
+func (s *MemoryStorage) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, key)
+	}
+
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+// Delete implements Storage
+func (s *MemoryStorage) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.objects, key)
+
+	return nil
+}
+
+// URL implements Storage
+func (s *MemoryStorage) URL(_ context.Context, key string, expires time.Duration) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.objects[key]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrFileNotFound, key)
+	}
+
+	return storageURL("memory:///", key, expires, nil)
+}
+
+// Keys returns the keys of all stored objects
+func (s *MemoryStorage) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// ContentType returns the content type an object was stored with
+func (s *MemoryStorage) ContentType(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.objects[key].contentType
+}
+
+// storageURL joins the key to base, escaping each of its segments, and adds an expires query parameter when expires
+// is positive; with a signing key, a signature query parameter covers the key and the expiry time
+func storageURL(base, key string, expires time.Duration, signingKey []byte) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(base, "/") + "/" + strings.Join(segments, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %w", err)
+	}
+
+	var expiresAt string
+
+	q := u.Query()
+
+	if expires > 0 {
+		expiresAt = strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+		q.Set("expires", expiresAt)
+	}
+
+	if len(signingKey) > 0 {
+		q.Set("signature", hex.EncodeToString(signStorageURL(signingKey, key, expiresAt)))
+	}
+
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// signStorageURL returns the HMAC-SHA256 of the key and expiry time of a storage URL
+func signStorageURL(signingKey []byte, key, expires string) []byte {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte(key + "\n" + expires))
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,318 @@
+package httpsling
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	local, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+
+	storages := map[string]Storage{
+		"local":  local,
+		"memory": NewMemoryStorage(),
+	}
+
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, err := storage.Get(ctx, "reports/a.txt")
+			require.ErrorIs(t, err, ErrFileNotFound)
+
+			require.NoError(t, storage.Put(ctx, "reports/a.txt", strings.NewReader("contents"), ContentTypeText))
+
+			rc, err := storage.Get(ctx, "reports/a.txt")
+			require.NoError(t, err)
+
+			b, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+			assert.Equal(t, "contents", string(b))
+
+			u, err := storage.URL(ctx, "reports/a.txt", time.Minute)
+			require.NoError(t, err)
+			assert.Contains(t, u, "reports/a.txt")
+
+			require.NoError(t, storage.Delete(ctx, "reports/a.txt"))
+			require.NoError(t, storage.Delete(ctx, "reports/a.txt"))
+
+			_, err = storage.Get(ctx, "reports/a.txt")
+			require.ErrorIs(t, err, ErrFileNotFound)
+		})
+	}
+}
+
+func TestLocalStorageKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewLocalStorage(dir)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	// keys can't escape the storage directory
+	require.NoError(t, s.Put(ctx, "../../escape.txt", strings.NewReader("a"), ContentTypeText))
+
+	p, err := s.path("../../escape.txt")
+	require.NoError(t, err)
+	assert.Equal(t, dir+"/escape.txt", p)
+
+	require.ErrorIs(t, s.Put(ctx, "/", strings.NewReader("a"), ContentTypeText), ErrInvalidStorageKey)
+
+	u, err := s.URL(ctx, "escape.txt", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "file://"+dir+"/escape.txt", u)
+
+	s.BaseURL = "https://files.example.com/uploads/"
+
+	u, err = s.URL(ctx, "escape.txt", time.Minute)
+	require.NoError(t, err)
+	assert.Contains(t, u, "https://files.example.com/uploads/escape.txt?expires=")
+	assert.NotContains(t, u, "signature=")
+
+	// the segments of keys are escaped
+	u, err = s.URL(ctx, "reports/a?b#c%d.txt", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "https://files.example.com/uploads/reports/a%3Fb%23c%25d.txt", u)
+}
+
+func TestLocalStorageSignedURL(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+
+	s.BaseURL = "https://files.example.com/uploads/"
+	s.SigningKey = []byte("secret")
+
+	ctx := context.Background()
+
+	raw, err := s.URL(ctx, "reports/a?b.txt", time.Minute)
+	require.NoError(t, err)
+	assert.Contains(t, raw, "signature=")
+
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+
+	key, err := s.VerifyURL(u)
+	require.NoError(t, err)
+	assert.Equal(t, "reports/a?b.txt", key)
+
+	// changing the expiry time invalidates the signature
+	q := u.Query()
+	q.Set("expires", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	tampered := *u
+	tampered.RawQuery = q.Encode()
+
+	_, err = s.VerifyURL(&tampered)
+	require.ErrorIs(t, err, ErrInvalidStorageURL)
+
+	// as does changing the key
+	tampered = *u
+	tampered.Path = "/uploads/reports/b.txt"
+
+	_, err = s.VerifyURL(&tampered)
+	require.ErrorIs(t, err, ErrInvalidStorageURL)
+
+	// expired URLs are rejected
+	raw, err = s.URL(ctx, "reports/a.txt", 0)
+	require.NoError(t, err)
+
+	u, err = url.Parse(raw)
+	require.NoError(t, err)
+
+	q = u.Query()
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	q.Set("expires", expired)
+	q.Set("signature", hex.EncodeToString(signStorageURL(s.SigningKey, "reports/a.txt", expired)))
+	u.RawQuery = q.Encode()
+
+	_, err = s.VerifyURL(u)
+	require.ErrorIs(t, err, ErrInvalidStorageURL)
+	assert.Contains(t, err.Error(), "expired")
+}
+
+func TestMemoryStorageURL(t *testing.T) {
+	s := NewMemoryStorage()
+	ctx := context.Background()
+
+	require.NoError(t, s.Put(ctx, "reports/a#1.txt", strings.NewReader("a"), ContentTypeText))
+
+	u, err := s.URL(ctx, "reports/a#1.txt", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "memory:///reports/a%231.txt", u)
+}
+
+func TestFileUploadMiddlewareStorage(t *testing.T) {
+	defaultStorage := NewMemoryStorage()
+	iconStorage := NewMemoryStorage()
+
+	r := MustNew(
+		WithStorage(defaultStorage),
+		WithFieldStorage("icon", iconStorage),
+		WithNameFuncGenerator(func(s string) string {
+			return "uploads/" + s
+		}),
+	)
+
+	var files Files
+
+	handler := r.FileUploadMiddleware("icon", "notes")(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		var err error
+
+		files, err = FilesFromContext(req, "icon")
+		require.NoError(t, err)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, uploadRequest(t, NewMultipart(
+		FileFromBytes("icon", "icon.png", pngHeader),
+		FileFromBytes("notes", "notes.txt", []byte("some notes")),
+	)))
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Equal(t, "uploads/icon.png", files["icon"][0].StorageKey)
+	assert.Equal(t, "4c4b6a3be1314ab86138bef4314dde022e600960d8689a2c8f8631802d20dab6", files["icon"][0].Checksum)
+	assert.Equal(t, []string{"uploads/icon.png"}, iconStorage.Keys())
+	assert.Equal(t, "image/png", iconStorage.ContentType("uploads/icon.png"))
+
+	assert.Equal(t, "uploads/notes.txt", files["notes"][0].StorageKey)
+	assert.Equal(t, []string{"uploads/notes.txt"}, defaultStorage.Keys())
+
+	rc, err := defaultStorage.Get(context.Background(), "uploads/notes.txt")
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "some notes", string(b))
+}
+
+type failingStorage struct {
+	*MemoryStorage
+}
+
+func (failingStorage) Put(context.Context, string, io.Reader, string) error {
+	return io.ErrShortWrite
+}
+
+func TestFileUploadMiddlewareUniqueKeys(t *testing.T) {
+	storage := NewMemoryStorage()
+	r := MustNew(WithStorage(storage))
+
+	var keys []string
+
+	handler := r.FileUploadMiddleware("notes")(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		files, err := FilesFromContext(req, "notes")
+		require.NoError(t, err)
+
+		keys = append(keys, files["notes"][0].StorageKey)
+	}))
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, uploadRequest(t, NewMultipart(FileFromBytes("notes", "../notes.txt", []byte("some notes")))))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	require.Len(t, keys, 2)
+	assert.NotEqual(t, keys[0], keys[1])
+	assert.Regexp(t, `^[0-9a-f]{32}-notes\.txt$`, keys[0])
+	assert.ElementsMatch(t, keys, storage.Keys())
+}
+
+func TestFileUploadMiddlewareStorageFailureKeepsExisting(t *testing.T) {
+	stored := NewMemoryStorage()
+	require.NoError(t, stored.Put(context.Background(), "a.txt", strings.NewReader("existing"), ContentTypeText))
+
+	other := NewMemoryStorage()
+
+	r := MustNew(
+		WithFieldStorage("a", stored),
+		WithFieldStorage("b", other),
+		WithNameFuncGenerator(func(s string) string { return s }),
+	)
+
+	handler := r.FileUploadMiddleware("a", "b")(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		t.Error("handler should not be called")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, uploadRequest(t, NewMultipart(
+		FileFromBytes("a", "a.txt", []byte("a")),
+		FileFromBytes("b", "b.txt", []byte("b")),
+	)))
+
+	// the object existed before the request, so it is neither replaced nor deleted, while the other file is
+	// rolled back if it was stored
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrStorageKeyExists.Error())
+	assert.Equal(t, []string{"a.txt"}, stored.Keys())
+	assert.Empty(t, other.Keys())
+
+	rc, err := stored.Get(context.Background(), "a.txt")
+	require.NoError(t, err)
+
+	defer rc.Close()
+
+	b, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "existing", string(b))
+}
+
+func TestFileUploadMiddlewareDuplicateNames(t *testing.T) {
+	stored := NewMemoryStorage()
+
+	r := MustNew(
+		WithStorage(stored),
+		WithNameFuncGenerator(func(string) string { return "upload" }),
+	)
+
+	handler := r.FileUploadMiddleware("a", "b")(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		t.Error("handler should not be called")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, uploadRequest(t, NewMultipart(
+		FileFromBytes("a", "a.txt", []byte("a")),
+		FileFromBytes("b", "b.txt", []byte("b")),
+	)))
+
+	// both files get the same name, so the second is rejected and the first rolled back
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Empty(t, stored.Keys())
+}
+
+func TestFileUploadMiddlewareStorageFailure(t *testing.T) {
+	stored := NewMemoryStorage()
+
+	r := MustNew(
+		WithFieldStorage("a", stored),
+		WithFieldStorage("b", failingStorage{NewMemoryStorage()}),
+	)
+
+	handler := r.FileUploadMiddleware("a", "b")(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		t.Error("handler should not be called")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, uploadRequest(t, NewMultipart(
+		FileFromBytes("a", "a.txt", []byte("a")),
+		FileFromBytes("b", "b.txt", []byte("b")),
+	)))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrFileStorageFailed.Error())
+	assert.Empty(t, stored.Keys())
+}
@@ -0,0 +1,371 @@
+package httpsling
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the tracer and meter of the telemetry middleware
+const instrumentationName = "github.com/theopenlane/httpsling"
+
+// durationBuckets are the histogram buckets recommended for HTTP request durations, in seconds
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// TelemetryConfig defines settings for the Telemetry middleware and TelemetryHandler
+type TelemetryConfig struct {
+	// TracerProvider creates the tracer (default the global TracerProvider)
+	TracerProvider trace.TracerProvider
+	// MeterProvider creates the meter (default the global MeterProvider)
+	MeterProvider metric.MeterProvider
+	// Propagator injects the trace context into requests and extracts it from received requests (default W3C
+	// trace context and baggage)
+	Propagator propagation.TextMapPropagator
+	// SpanName names the span of a request (default the request method)
+	SpanName func(req *http.Request) string
+	// Redactor redacts the query parameters of the url.full attribute of client spans, which never includes
+	// credentials; nil redacts the values of all query parameters
+	Redactor *Redactor
+}
+
+func (c *TelemetryConfig) normalize() {
+	if c.TracerProvider == nil {
+		c.TracerProvider = otel.GetTracerProvider()
+	}
+
+	if c.MeterProvider == nil {
+		c.MeterProvider = otel.GetMeterProvider()
+	}
+
+	if c.Propagator == nil {
+		c.Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	}
+
+	if c.SpanName == nil {
+		c.SpanName = func(req *http.Request) string {
+			return req.Method
+		}
+	}
+}
+
+// instruments are the metrics recorded for requests
+type instruments struct {
+	duration     metric.Float64Histogram
+	requestSize  metric.Int64Histogram
+	responseSize metric.Int64Histogram
+}
+
+// newInstruments creates the duration and body size histograms; errors are reported to the global otel error
+// handler, leaving a no-op instrument
+func newInstruments(meter metric.Meter, prefix string) instruments {
+	var (
+		i   instruments
+		err error
+	)
+
+	i.duration, err = meter.Float64Histogram(prefix+".request.duration", metric.WithUnit("s"),
+		metric.WithDescription("Duration of HTTP "+strings.TrimPrefix(prefix, "http.")+" requests."),
+		metric.WithExplicitBucketBoundaries(durationBuckets...))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	i.requestSize, err = meter.Int64Histogram(prefix+".request.body.size", metric.WithUnit("By"),
+		metric.WithDescription("Size of HTTP "+strings.TrimPrefix(prefix, "http.")+" request bodies."))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	i.responseSize, err = meter.Int64Histogram(prefix+".response.body.size", metric.WithUnit("By"),
+		metric.WithDescription("Size of HTTP "+strings.TrimPrefix(prefix, "http.")+" response bodies."))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return i
+}
+
+// Telemetry traces requests with OpenTelemetry client spans following the HTTP semantic conventions, injects the
+// trace context into the request headers, and records the http.client.request.duration,
+// http.client.request.body.size and http.client.response.body.size histograms. The span ends once the response
+// body is read to the end or closed. Installed after Retry, each attempt gets its own span with the
+// http.request.resend_count attribute; installed before it, the span covers all attempts
+func Telemetry(config *TelemetryConfig) Middleware {
+	c := TelemetryConfig{}
+	if config != nil {
+		c = *config
+	}
+
+	c.normalize()
+
+	tracer := c.TracerProvider.Tracer(instrumentationName)
+	inst := newInstruments(c.MeterProvider.Meter(instrumentationName), "http.client")
+
+	rd := &redactor{allQueryParams: true}
+	if c.Redactor != nil {
+		rd = c.Redactor.compile()
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			attrs := clientAttributes(req)
+
+			ctx, span := tracer.Start(req.Context(), c.SpanName(req),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...),
+				trace.WithAttributes(semconv.URLFull(redactedURL(rd, req))),
+			)
+
+			traced := req.WithContext(ctx)
+			traced.Header = req.Header.Clone()
+
+			if traced.Header == nil {
+				traced.Header = http.Header{}
+			}
+
+			c.Propagator.Inject(ctx, propagation.HeaderCarrier(traced.Header))
+
+			if req.ContentLength > 0 {
+				inst.requestSize.Record(ctx, req.ContentLength, metric.WithAttributes(attrs...))
+			}
+
+			resp, err := next.Do(traced)
+
+			attempt := RetryAttempt(req.Context())
+			if attempt == 0 {
+				attempt = RetryAttempts(resp)
+			}
+
+			if attempt > 1 {
+				span.SetAttributes(semconv.HTTPRequestResendCount(attempt - 1))
+			}
+
+			if err != nil {
+				errType := fmt.Sprintf("%T", err)
+				attrs = append(attrs, semconv.ErrorTypeKey.String(errType))
+
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				span.SetAttributes(semconv.ErrorTypeKey.String(errType))
+				span.End()
+
+				inst.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+
+				return resp, err
+			}
+
+			attrs = append(attrs, semconv.HTTPResponseStatusCode(resp.StatusCode))
+			span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+
+			if resp.StatusCode >= http.StatusBadRequest {
+				attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+				span.SetStatus(codes.Error, "")
+				span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+			}
+
+			body := &tracedBody{body: resp.Body}
+			body.end = func(readErr error) {
+				if readErr != nil {
+					span.RecordError(readErr)
+				}
+
+				span.End()
+
+				opt := metric.WithAttributes(attrs...)
+				inst.duration.Record(ctx, time.Since(start).Seconds(), opt)
+				inst.responseSize.Record(ctx, body.n, opt)
+			}
+
+			if resp.Body == nil || resp.Body == http.NoBody {
+				body.finish(nil)
+
+				return resp, nil
+			}
+
+			resp.Body = body
+
+			return resp, nil
+		})
+	}
+}
+
+// tracedBody counts the bytes of a response body, ending its span at the end of the body or when it is closed
+type tracedBody struct {
+	body io.ReadCloser
+	n    int64
+	end  func(err error)
+	once sync.Once
+}
+
+func (b *tracedBody) finish(err error) {
+	b.once.Do(func() {
+		b.end(err)
+	})
+}
+
+// Read implements io.Reader
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.n += int64(n)
+
+	switch {
+	case err == io.EOF:
+		b.finish(nil)
+	case err != nil:
+		b.finish(err)
+	}
+
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *tracedBody) Close() error {
+	err := b.body.Close()
+	b.finish(nil)
+
+	return err
+}
+
+// clientAttributes returns the low cardinality attributes of a client request, which are shared by its span and
+// its metrics
+func clientAttributes(req *http.Request) []attribute.KeyValue {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	attrs := []attribute.KeyValue{semconv.HTTPRequestMethodKey.String(req.Method), semconv.URLScheme(req.URL.Scheme)}
+
+	return append(attrs, serverAttributes(host, req.URL.Scheme)...)
+}
+
+// serverAttributes returns the server.address and server.port attributes of a host, with the default port of
+// the scheme if it has none
+func serverAttributes(host, scheme string) []attribute.KeyValue {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname = host
+
+		switch scheme {
+		case "https":
+			port = "443"
+		case "http":
+			port = "80"
+		}
+	}
+
+	attrs := []attribute.KeyValue{semconv.ServerAddress(hostname)}
+
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, semconv.ServerPort(p))
+	}
+
+	return attrs
+}
+
+// redactedURL returns the URL of the request without credentials, with its query parameters redacted
+func redactedURL(rd *redactor, req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+
+	return rd.url(&u)
+}
+
+// TelemetryHandler returns http middleware which traces requests received by a server with OpenTelemetry server
+// spans, continuing the trace context propagated by the client, and records the http.server.request.duration,
+// http.server.request.body.size and http.server.response.body.size histograms
+func TelemetryHandler(config *TelemetryConfig) func(http.Handler) http.Handler {
+	c := TelemetryConfig{}
+	if config != nil {
+		c = *config
+	}
+
+	c.normalize()
+
+	tracer := c.TracerProvider.Tracer(instrumentationName)
+	inst := newInstruments(c.MeterProvider.Meter(instrumentationName), "http.server")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			ctx := c.Propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			scheme := "http"
+			if req.TLS != nil {
+				scheme = "https"
+			}
+
+			attrs := append([]attribute.KeyValue{semconv.HTTPRequestMethodKey.String(req.Method), semconv.URLScheme(scheme)},
+				serverAttributes(req.Host, scheme)...)
+
+			spanAttrs := []attribute.KeyValue{semconv.URLPath(req.URL.Path)}
+
+			if ua := req.UserAgent(); ua != "" {
+				spanAttrs = append(spanAttrs, semconv.UserAgentOriginal(ua))
+			}
+
+			if clientAddr, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+				spanAttrs = append(spanAttrs, semconv.ClientAddress(clientAddr))
+			}
+
+			ctx, span := tracer.Start(ctx, c.SpanName(req),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attrs...),
+				trace.WithAttributes(spanAttrs...),
+			)
+			defer span.End()
+
+			body := &countingReader{ReadCloser: req.Body}
+			if req.Body != nil {
+				req.Body = body
+			}
+
+			m := httpsnoop.CaptureMetricsFn(w, func(w http.ResponseWriter) {
+				next.ServeHTTP(w, req.WithContext(ctx))
+			})
+
+			attrs = append(attrs, semconv.HTTPResponseStatusCode(m.Code))
+			span.SetAttributes(semconv.HTTPResponseStatusCode(m.Code))
+
+			if m.Code >= http.StatusInternalServerError {
+				attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(m.Code)))
+				span.SetStatus(codes.Error, "")
+				span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(m.Code)))
+			}
+
+			opt := metric.WithAttributes(attrs...)
+			inst.duration.Record(ctx, time.Since(start).Seconds(), opt)
+			inst.requestSize.Record(ctx, body.n, opt)
+			inst.responseSize.Record(ctx, m.Written, opt)
+		})
+	}
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read implements io.Reader
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,262 @@
+package httpsling_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+type telemetry struct {
+	spans  *tracetest.InMemoryExporter
+	reader *sdkmetric.ManualReader
+	config *httpsling.TelemetryConfig
+}
+
+func newTelemetry() *telemetry {
+	spans := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+
+	return &telemetry{
+		spans:  spans,
+		reader: reader,
+		config: &httpsling.TelemetryConfig{
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)),
+			MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		},
+	}
+}
+
+// histogram returns the data points of the histogram with the name
+func (tel *telemetry) histogram(t *testing.T, name string) []attribute.Set {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, tel.reader.Collect(context.Background(), &rm))
+
+	var sets []attribute.Set
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+
+			switch data := m.Data.(type) {
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					sets = append(sets, dp.Attributes)
+				}
+			case metricdata.Histogram[int64]:
+				for _, dp := range data.DataPoints {
+					sets = append(sets, dp.Attributes)
+				}
+			}
+		}
+	}
+
+	return sets
+}
+
+func spanAttr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+
+	return attribute.Value{}
+}
+
+func TestTelemetry(t *testing.T) {
+	var traceparent string
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+
+		w.Write([]byte("pong")) // nolint: errcheck
+	}))
+	defer s.Close()
+
+	tel := newTelemetry()
+
+	resp, err := httptestutil.Requester(s, httpsling.Telemetry(tel.config)).Send(httpsling.Post("/ping"), httpsling.Body("ping"))
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(b))
+	require.NoError(t, resp.Body.Close())
+
+	spans := tel.spans.GetSpans()
+	require.Len(t, spans, 1)
+
+	span := spans[0]
+	assert.Equal(t, "POST", span.Name)
+	assert.Equal(t, trace.SpanKindClient, span.SpanKind)
+	assert.Equal(t, "POST", spanAttr(span, "http.request.method").AsString())
+	assert.Equal(t, s.URL+"/ping", spanAttr(span, "url.full").AsString())
+	assert.Equal(t, "127.0.0.1", spanAttr(span, "server.address").AsString())
+	assert.EqualValues(t, 200, spanAttr(span, "http.response.status_code").AsInt64())
+	assert.Equal(t, codes.Unset, span.Status.Code)
+
+	assert.Equal(t, "00-"+span.SpanContext.TraceID().String()+"-"+span.SpanContext.SpanID().String()+"-01", traceparent)
+
+	for _, name := range []string{"http.client.request.duration", "http.client.request.body.size", "http.client.response.body.size"} {
+		sets := tel.histogram(t, name)
+		require.Len(t, sets, 1, name)
+
+		method, _ := sets[0].Value("http.request.method")
+		assert.Equal(t, "POST", method.AsString(), name)
+	}
+
+	status, _ := tel.histogram(t, "http.client.request.duration")[0].Value("http.response.status_code")
+	assert.EqualValues(t, 200, status.AsInt64())
+}
+
+func TestTelemetryRedactsURL(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(http.StatusOK))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+
+	u.User = url.UserPassword("user", "pass")
+	u.Path = "/ping"
+	u.RawQuery = "api_key=secret&page=2"
+
+	tests := []struct {
+		name     string
+		redactor *httpsling.Redactor
+		want     string
+	}{
+		{name: "default", want: s.URL + "/ping?api_key=[REDACTED]&page=[REDACTED]"},
+		{
+			name:     "redactor",
+			redactor: &httpsling.Redactor{QueryParams: []string{"api_key"}},
+			want:     s.URL + "/ping?api_key=[REDACTED]&page=2",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tel := newTelemetry()
+			tel.config.Redactor = tc.redactor
+
+			resp, err := httpsling.Send(httpsling.Get(u.String()), httpsling.Use(httpsling.Telemetry(tel.config)))
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			spans := tel.spans.GetSpans()
+			require.Len(t, spans, 1)
+			assert.Equal(t, tc.want, spanAttr(spans[0], "url.full").AsString())
+		})
+	}
+}
+
+func TestTelemetryErrors(t *testing.T) {
+	tel := newTelemetry()
+
+	s := httptest.NewServer(httpsling.MockHandler(503))
+	defer s.Close()
+
+	resp, err := httptestutil.Requester(s, httpsling.Telemetry(tel.config)).Receive(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 503, resp.StatusCode)
+
+	failing := httpsling.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("boom")
+	})
+
+	_, err = httpsling.MustNew(httpsling.WithDoer(failing), httpsling.Telemetry(tel.config)).Send(httpsling.Get("http://example.com"))
+	require.Error(t, err)
+
+	spans := tel.spans.GetSpans()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "503", spanAttr(spans[0], "error.type").AsString())
+
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+	assert.Equal(t, "*errors.errorString", spanAttr(spans[1], "error.type").AsString())
+	require.Len(t, spans[1].Events, 1)
+	assert.Equal(t, "exception", spans[1].Events[0].Name)
+}
+
+func TestTelemetryRetry(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(503))
+	defer s.Close()
+
+	retry := httpsling.Retry(&httpsling.RetryConfig{MaxAttempts: 3, Backoff: httpsling.NoBackoff()})
+
+	t.Run("span per attempt", func(t *testing.T) {
+		tel := newTelemetry()
+
+		_, err := httptestutil.Requester(s, retry, httpsling.Telemetry(tel.config)).Receive(nil)
+		require.NoError(t, err)
+
+		spans := tel.spans.GetSpans()
+		require.Len(t, spans, 3)
+
+		assert.False(t, spanAttr(spans[0], "http.request.resend_count").Type() == attribute.INT64)
+		assert.EqualValues(t, 1, spanAttr(spans[1], "http.request.resend_count").AsInt64())
+		assert.EqualValues(t, 2, spanAttr(spans[2], "http.request.resend_count").AsInt64())
+	})
+
+	t.Run("span around attempts", func(t *testing.T) {
+		tel := newTelemetry()
+
+		_, err := httptestutil.Requester(s, httpsling.Telemetry(tel.config), retry).Receive(nil)
+		require.NoError(t, err)
+
+		spans := tel.spans.GetSpans()
+		require.Len(t, spans, 1)
+
+		assert.EqualValues(t, 2, spanAttr(spans[0], "http.request.resend_count").AsInt64())
+	})
+}
+
+func TestTelemetryHandler(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(201, httpsling.Body("created")))
+	defer s.Close()
+
+	tel := newTelemetry()
+	httptestutil.Trace(s, tel.config)
+
+	resp, err := httptestutil.Requester(s, httpsling.Telemetry(tel.config)).Receive(nil, httpsling.Put("/things/1"), httpsling.Body("thing"))
+	require.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	spans := tel.spans.GetSpans()
+	require.Len(t, spans, 2)
+
+	server, client := spans[0], spans[1]
+	if server.SpanKind != trace.SpanKindServer {
+		server, client = client, server
+	}
+
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, client.SpanContext.TraceID(), server.SpanContext.TraceID())
+	assert.Equal(t, client.SpanContext.SpanID(), server.Parent.SpanID())
+	assert.Equal(t, "/things/1", spanAttr(server, "url.path").AsString())
+	assert.EqualValues(t, 201, spanAttr(server, "http.response.status_code").AsInt64())
+
+	require.Len(t, tel.histogram(t, "http.server.request.duration"), 1)
+	require.Len(t, tel.histogram(t, "http.server.response.body.size"), 1)
+}
@@ -0,0 +1,257 @@
+package httpsling
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenExpirySkew is how long before their expiry tokens are refreshed by default
+const DefaultTokenExpirySkew = 10 * time.Second
+
+// Token is an access token issued by an OAuth2 token endpoint (RFC 6749 section 5.1)
+type Token struct {
+	// AccessToken is the token sent with requests
+	AccessToken string `json:"access_token"`
+	// TokenType is the type of the token, usually Bearer
+	TokenType string `json:"token_type,omitempty"`
+	// RefreshToken can be used to obtain a new access token
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// ExpiresIn is the lifetime of the token in seconds, as returned by the token endpoint
+	ExpiresIn int64 `json:"expires_in,omitempty"`
+	// Scope is the scope of the token
+	Scope string `json:"scope,omitempty"`
+	// Expiry is the time the token expires; the zero value means it never expires
+	Expiry time.Time `json:"-"`
+}
+
+// Valid returns true if the token is set and doesn't expire within skew
+func (t *Token) Valid(skew time.Duration) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || time.Until(t.Expiry) > skew)
+}
+
+// authorization returns the value of the Authorization header for the token
+func (t *Token) authorization() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "bearer") {
+		return BearerAuthHeader + t.AccessToken
+	}
+
+	return t.TokenType + " " + t.AccessToken
+}
+
+// TokenSource supplies the tokens used by the TokenAuth middleware to authorize requests
+type TokenSource interface {
+	// Token returns the token to send with the next request
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenSourceFunc adapts a function to the TokenSource interface
+type TokenSourceFunc func(ctx context.Context) (*Token, error)
+
+// Token implements TokenSource
+func (f TokenSourceFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// TokenAuth authorizes each request with the token returned by the TokenSource, so credentials can change without
+// rebuilding the Requester. If the upstream rejects the token with a 401 and an invalid_token WWW-Authenticate
+// challenge, the token is dropped from a CachedTokenSource, and the request is sent once more if the source then
+// returns a different token and the request body can be replayed
+func TokenAuth(src TokenSource) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			tok, err := src.Token(req.Context())
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := next.Do(authorize(req, tok))
+			if err != nil || resp.StatusCode != http.StatusUnauthorized || !invalidTokenChallenge(resp) || bodyEmpty(req) {
+				return resp, err
+			}
+
+			if cached, ok := src.(*cachedTokenSource); ok {
+				cached.invalidate(tok)
+			}
+
+			newTok, err := src.Token(req.Context())
+			if err != nil || newTok.AccessToken == tok.AccessToken {
+				return resp, nil
+			}
+
+			retryReq, err := resetRequest(req)
+			if err != nil {
+				return resp, nil
+			}
+
+			drain(resp.Body)
+
+			return next.Do(authorize(retryReq, newTok))
+		})
+	}
+}
+
+// authorize returns a copy of the request carrying the token
+func authorize(req *http.Request, tok *Token) *http.Request {
+	authorized := *req
+	authorized.Header = req.Header.Clone()
+	authorized.Header.Set(HeaderAuthorization, tok.authorization())
+
+	return &authorized
+}
+
+// invalidTokenChallenge returns true if the response asks for a new token (RFC 6750 section 3.1)
+func invalidTokenChallenge(resp *http.Response) bool {
+	for _, challenge := range resp.Header.Values(HeaderWWWAuthenticate) {
+		if strings.EqualFold(structuredParam(challenge, "error"), "invalid_token") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StaticToken returns a TokenSource which always returns the same bearer token
+func StaticToken(token string) TokenSource {
+	tok := &Token{AccessToken: token}
+
+	return TokenSourceFunc(func(context.Context) (*Token, error) {
+		if tok.AccessToken == "" {
+			return nil, ErrMissingAccessToken
+		}
+
+		return tok, nil
+	})
+}
+
+// EnvToken returns a TokenSource which reads the bearer token from the environment variable on every request
+func EnvToken(name string) TokenSource {
+	return TokenSourceFunc(func(context.Context) (*Token, error) {
+		token := strings.TrimSpace(os.Getenv(name))
+		if token == "" {
+			return nil, fmt.Errorf("%w: environment variable %s is not set", ErrMissingAccessToken, name)
+		}
+
+		return &Token{AccessToken: token}, nil
+	})
+}
+
+// FileToken returns a TokenSource which reads the bearer token from a file, reloading it whenever the file
+// changes, e.g. a projected Kubernetes service account token which is rotated by the kubelet
+func FileToken(path string) TokenSource {
+	return &fileTokenSource{path: path}
+}
+
+type fileTokenSource struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	tok     *Token
+}
+
+// Token implements TokenSource
+func (s *fileTokenSource) Token(context.Context) (*Token, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading token file: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tok != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.tok, nil
+	}
+
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading token file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return nil, fmt.Errorf("%w: token file %s is empty", ErrMissingAccessToken, s.path)
+	}
+
+	s.tok = &Token{AccessToken: token}
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+
+	return s.tok, nil
+}
+
+// CachedTokenSource returns a TokenSource which caches the tokens of src until skew before they expire (by
+// default DefaultTokenExpirySkew); concurrent requests wait for a single call to src. Tokens without an expiry
+// are cached until the upstream rejects them
+func CachedTokenSource(src TokenSource, skew time.Duration) TokenSource {
+	if skew == 0 {
+		skew = DefaultTokenExpirySkew
+	}
+
+	return &cachedTokenSource{src: src, skew: skew, sem: make(chan struct{}, 1)}
+}
+
+type cachedTokenSource struct {
+	src  TokenSource
+	skew time.Duration
+
+	mu  sync.RWMutex
+	tok *Token
+
+	// sem is held while fetching a token, so concurrent requests wait for a single fetch
+	sem chan struct{}
+}
+
+func (s *cachedTokenSource) current() *Token {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.tok
+}
+
+// invalidate drops the cached token, unless it was already replaced
+func (s *cachedTokenSource) invalidate(tok *Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tok == tok {
+		s.tok = nil
+	}
+}
+
+// Token implements TokenSource
+func (s *cachedTokenSource) Token(ctx context.Context) (*Token, error) {
+	if tok := s.current(); tok.Valid(s.skew) {
+		return tok, nil
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	defer func() { <-s.sem }()
+
+	// another request may have fetched a token while this one was waiting
+	if tok := s.current(); tok.Valid(s.skew) {
+		return tok, nil
+	}
+
+	tok, err := s.src.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.tok = tok
+	s.mu.Unlock()
+
+	return tok, nil
+}
@@ -0,0 +1,140 @@
+package httpsling_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theopenlane/httpsling"
+	"github.com/theopenlane/httpsling/httptestutil"
+)
+
+// authorization sends a request through the TokenSource and returns the Authorization header the server received
+func authorization(t *testing.T, src httpsling.TokenSource) string {
+	t.Helper()
+
+	s := httptest.NewServer(authEcho)
+	defer s.Close()
+
+	var out string
+
+	resp, err := httptestutil.Requester(s, httpsling.TokenAuth(src)).Receive(&out)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	return out
+}
+
+func TestStaticToken(t *testing.T) {
+	assert.Equal(t, "Bearer static", authorization(t, httpsling.StaticToken("static")))
+
+	_, err := httpsling.StaticToken("").Token(context.Background())
+	require.ErrorIs(t, err, httpsling.ErrMissingAccessToken)
+}
+
+func TestEnvToken(t *testing.T) {
+	src := httpsling.EnvToken("HTTPSLING_TEST_TOKEN")
+
+	_, err := src.Token(context.Background())
+	require.ErrorIs(t, err, httpsling.ErrMissingAccessToken)
+
+	t.Setenv("HTTPSLING_TEST_TOKEN", "first\n")
+	assert.Equal(t, "Bearer first", authorization(t, src))
+
+	t.Setenv("HTTPSLING_TEST_TOKEN", "second")
+	assert.Equal(t, "Bearer second", authorization(t, src))
+}
+
+func TestFileToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	src := httpsling.FileToken(path)
+
+	_, err := src.Token(context.Background())
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, os.WriteFile(path, []byte("first\n"), 0o600))
+	assert.Equal(t, "Bearer first", authorization(t, src))
+
+	// the rotated token is picked up
+	require.NoError(t, os.WriteFile(path, []byte("second\n"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	assert.Equal(t, "Bearer second", authorization(t, src))
+
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	_, err = src.Token(context.Background())
+	require.ErrorIs(t, err, httpsling.ErrMissingAccessToken)
+}
+
+func TestCachedTokenSource(t *testing.T) {
+	var calls atomic.Int32
+
+	expiresIn := time.Hour
+
+	src := httpsling.CachedTokenSource(httpsling.TokenSourceFunc(func(context.Context) (*httpsling.Token, error) {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+
+		return &httpsling.Token{AccessToken: "cached", Expiry: time.Now().Add(expiresIn)}, nil
+	}), time.Minute)
+
+	var wg sync.WaitGroup
+
+	for range 5 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			tok, err := src.Token(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "cached", tok.AccessToken)
+		}()
+	}
+
+	wg.Wait()
+
+	assert.EqualValues(t, 1, calls.Load())
+
+	// tokens expiring within the skew are refreshed on every call
+	src = httpsling.CachedTokenSource(httpsling.TokenSourceFunc(func(context.Context) (*httpsling.Token, error) {
+		calls.Add(1)
+
+		return &httpsling.Token{AccessToken: "short", Expiry: time.Now().Add(time.Second)}, nil
+	}), 0)
+
+	for range 2 {
+		_, err := src.Token(context.Background())
+		require.NoError(t, err)
+	}
+
+	assert.EqualValues(t, 3, calls.Load())
+}
+
+func TestTokenAuthRejected(t *testing.T) {
+	s := httptest.NewServer(httpsling.MockHandler(http.StatusUnauthorized,
+		httpsling.Header(httpsling.HeaderWWWAuthenticate, `Bearer error="invalid_token"`),
+	))
+	defer s.Close()
+
+	i := httptestutil.Inspect(s)
+
+	// the request is not sent again if the source has no other token
+	resp, err := httptestutil.Requester(s, httpsling.TokenAuth(httpsling.StaticToken("revoked"))).Receive(nil)
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Len(t, i.Drain(), 1)
+}
@@ -0,0 +1,269 @@
+package httpsling
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// defaultUploadMemory is the number of bytes of an upload kept in memory while parsing; the remainder is stored in temporary files
+const defaultUploadMemory = 32 << 20
+
+// sniffLen is the number of bytes used to detect the mime type of an uploaded file
+const sniffLen = 512
+
+// FileUploadMiddleware returns http middleware which parses the files sent in the given multipart form fields,
+// using the MaxFileSize, MaxUploadSize, ValidationFunc, NameGeneratorFunc and ErrResponseHandler options of the Requester.
+// The parsed files are persisted to the Storage configured for their field, and stored in the request context under each
+// field key, where they can be read with FilesFromContext
+func (r *Requester) FileUploadMiddleware(keys ...string) func(http.Handler) http.Handler {
+	errHandler := r.fileUploaderrorResponseHandler
+	if errHandler == nil {
+		errHandler = DefaultFileErrorResponseHandler
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			files, err := r.parseUploads(w, req, keys)
+			if err == nil {
+				err = r.storeUploads(req.Context(), files)
+			}
+
+			if err != nil {
+				errHandler(err).ServeHTTP(w, req)
+
+				return
+			}
+
+			ctx := req.Context()
+
+			for key := range files {
+				ctx = context.WithValue(ctx, key, files) // nolint: staticcheck
+			}
+
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
+
+// parseUploads parses the multipart form of the request and validates each file sent under one of the keys
+func (r *Requester) parseUploads(w http.ResponseWriter, req *http.Request, keys []string) (Files, error) {
+	if r.MaxUploadSize > 0 {
+		req.Body = http.MaxBytesReader(w, req.Body, r.MaxUploadSize)
+	}
+
+	if err := req.ParseMultipartForm(defaultUploadMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, fmt.Errorf("%w: limit is %d bytes", ErrUploadTooLarge, maxBytesErr.Limit)
+		}
+
+		return nil, fmt.Errorf("error parsing multipart form: %w", err)
+	}
+
+	files := Files{}
+
+	for _, key := range keys {
+		for _, header := range req.MultipartForm.File[key] {
+			if r.MaxFileSize > 0 && header.Size > r.MaxFileSize {
+				return nil, fmt.Errorf("%w: %s is %d bytes, limit is %d bytes", ErrFileTooLarge, header.Filename, header.Size, r.MaxFileSize)
+			}
+
+			f := File{
+				FieldName:        key,
+				OriginalName:     header.Filename,
+				UploadedFileName: header.Filename,
+				Size:             header.Size,
+				header:           header,
+			}
+
+			mimeType, err := detectMimeType(f)
+			if err != nil {
+				return nil, err
+			}
+
+			f.MimeType = mimeType
+
+			if r.fileNameFuncGenerator != nil {
+				f.UploadedFileName = r.fileNameFuncGenerator(header.Filename)
+			}
+
+			if r.validationFunc != nil {
+				if err := r.validationFunc(f); err != nil {
+					return nil, err
+				}
+			}
+
+			files[key] = append(files[key], f)
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, ErrNoFilesUploaded
+	}
+
+	return files, nil
+}
+
+// storageFor returns the Storage files uploaded in the form field are persisted to, or nil
+func (r *Requester) storageFor(fieldName string) Storage {
+	if s, ok := r.fieldStorage[fieldName]; ok {
+		return s
+	}
+
+	return r.fileStorage
+}
+
+// storeUploads persists the files to their configured Storage, recording the storage key and checksum; if any file
+// fails to be stored, the objects this request created are deleted again
+func (r *Requester) storeUploads(ctx context.Context, files Files) (err error) {
+	type stored struct {
+		storage Storage
+		key     string
+	}
+
+	var done []stored
+
+	written := map[string]bool{}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		for _, s := range done {
+			_ = s.storage.Delete(ctx, s.key)
+		}
+	}()
+
+	for fieldName, fieldFiles := range files {
+		storage := r.storageFor(fieldName)
+		if storage == nil {
+			continue
+		}
+
+		for i := range fieldFiles {
+			created, err := storeUpload(ctx, storage, &fieldFiles[i], r.fileNameFuncGenerator == nil, written)
+			if created {
+				done = append(done, stored{storage: storage, key: fieldFiles[i].StorageKey})
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// storeUpload streams a single file to the storage while computing its checksum. Unless the key is unique, it is the
+// name from the NameGeneratorFunc; rather than replacing an object which is already stored, or was written earlier
+// in the request, it fails with ErrStorageKeyExists. created reports whether an object may have been written
+func storeUpload(ctx context.Context, storage Storage, f *File, unique bool, written map[string]bool) (created bool,
+	err error) {
+	file, err := f.Open()
+	if err != nil {
+		return false, err
+	}
+
+	defer file.Close()
+
+	key := f.UploadedFileName
+
+	if unique {
+		key, err = uniqueStorageKey(key)
+		if err != nil {
+			return false, err
+		}
+	} else if written[key] {
+		return false, fmt.Errorf("%w: %s: %s", ErrStorageKeyExists, f.OriginalName, key)
+	} else if existing, err := storage.Get(ctx, key); err == nil {
+		existing.Close()
+
+		return false, fmt.Errorf("%w: %s: %s", ErrStorageKeyExists, f.OriginalName, key)
+	}
+
+	hash := sha256.New()
+	f.StorageKey = key
+	written[key] = true
+
+	if err := storage.Put(ctx, key, io.TeeReader(file, hash), f.MimeType); err != nil {
+		// a failed Put may leave a partial object behind
+		return true, fmt.Errorf("%w: %s: %w", ErrFileStorageFailed, f.OriginalName, err)
+	}
+
+	f.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	return true, nil
+}
+
+// uniqueStorageKey prefixes the base name of the uploaded file with random bytes, so uploads with the same name
+// don't replace each other
+func uniqueStorageKey(name string) (string, error) {
+	b := make([]byte, 16) // nolint: mnd
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	prefix := hex.EncodeToString(b)
+
+	base := path.Base("/" + name)
+	if base == "/" {
+		return prefix, nil
+	}
+
+	return prefix + "-" + base, nil
+}
+
+// detectMimeType sniffs the mime type from the contents of the file, ignoring the content type sent by the client
+func detectMimeType(f File) (string, error) {
+	file, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	buf := make([]byte, sniffLen)
+
+	n, err := io.ReadFull(file, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("error reading uploaded file %s: %w", f.OriginalName, err)
+	}
+
+	mimeType, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedMimeType, err)
+	}
+
+	return mimeType, nil
+}
+
+// DefaultFileErrorResponseHandler is the ErrResponseHandler used when none is configured; it writes the error
+// as a plain text response with a status code matching the cause of the failure
+func DefaultFileErrorResponseHandler(err error) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		status := http.StatusBadRequest
+
+		switch {
+		case errors.Is(err, ErrFileTooLarge), errors.Is(err, ErrUploadTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		case errors.Is(err, ErrUnsupportedMimeType):
+			status = http.StatusUnsupportedMediaType
+		case errors.Is(err, ErrStorageKeyExists):
+			status = http.StatusConflict
+		case errors.Is(err, ErrFileStorageFailed):
+			status = http.StatusInternalServerError
+		}
+
+		http.Error(w, err.Error(), status)
+	}
+}
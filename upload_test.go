@@ -0,0 +1,162 @@
+package httpsling
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+func uploadRequest(t *testing.T, mp *Multipart) *http.Request {
+	t.Helper()
+
+	req, err := Request(Post("http://example.com/upload"), mp)
+	require.NoError(t, err)
+
+	return req
+}
+
+func TestFileUploadMiddleware(t *testing.T) {
+	r := MustNew(
+		WithMaxFileSize(1024),
+		WithValidationFunc(ChainValidators(MimeTypeValidator("image/png", "text/plain"))),
+		WithNameFuncGenerator(func(s string) string {
+			return "renamed-" + s
+		}),
+	)
+
+	var (
+		files    Files
+		contents string
+	)
+
+	handler := r.FileUploadMiddleware("icon", "notes")(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		var err error
+
+		files, err = FilesFromContext(req, "icon")
+		require.NoError(t, err)
+
+		notes, err := FilesFromContextWithKey(req, "notes")
+		require.NoError(t, err)
+		require.Len(t, notes, 1)
+
+		f, err := notes[0].Open()
+		require.NoError(t, err)
+
+		defer f.Close()
+
+		b, err := io.ReadAll(f)
+		require.NoError(t, err)
+
+		contents = string(b)
+	}))
+
+	// the content type sent by the client is ignored in favor of the sniffed type
+	icon := FileFromBytes("icon", "icon.png", append(pngHeader, []byte("pixels")...))
+	icon.ContentType = ContentTypeJSON
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, uploadRequest(t, NewMultipart(
+		icon,
+		FileFromBytes("notes", "notes.txt", []byte("some notes")),
+		FileFromBytes("ignored", "ignored.txt", []byte("not parsed")),
+	)))
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	require.Len(t, files["icon"], 1)
+	assert.Equal(t, "icon", files["icon"][0].FieldName)
+	assert.Equal(t, "icon.png", files["icon"][0].OriginalName)
+	assert.Equal(t, "renamed-icon.png", files["icon"][0].UploadedFileName)
+	assert.Equal(t, "image/png", files["icon"][0].MimeType)
+	assert.EqualValues(t, 14, files["icon"][0].Size)
+	assert.Equal(t, "text/plain", files["notes"][0].MimeType)
+	assert.NotContains(t, files, "ignored")
+	assert.Equal(t, "some notes", contents)
+}
+
+func TestFileUploadMiddlewareErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		files      []*MultipartFile
+		expectCode int
+		expectBody string
+	}{
+		{
+			name:       "file too large",
+			opts:       []Option{WithMaxFileSize(4)},
+			files:      []*MultipartFile{FileFromBytes("file", "a.txt", []byte("too large"))},
+			expectCode: http.StatusRequestEntityTooLarge,
+			expectBody: ErrFileTooLarge.Error(),
+		},
+		{
+			name:       "upload too large",
+			opts:       []Option{WithMaxUploadSize(64)},
+			files:      []*MultipartFile{FileFromBytes("file", "a.txt", []byte(strings.Repeat("a", 128)))},
+			expectCode: http.StatusRequestEntityTooLarge,
+			expectBody: ErrUploadTooLarge.Error(),
+		},
+		{
+			name:       "unsupported mime type",
+			opts:       []Option{WithValidationFunc(MimeTypeValidator("image/png"))},
+			files:      []*MultipartFile{FileFromBytes("file", "a.png", []byte("not a png"))},
+			expectCode: http.StatusUnsupportedMediaType,
+			expectBody: "unsupported mime type: text/plain",
+		},
+		{
+			name:       "no files",
+			files:      []*MultipartFile{FileFromBytes("other", "a.txt", []byte("a"))},
+			expectCode: http.StatusBadRequest,
+			expectBody: ErrNoFilesUploaded.Error(),
+		},
+		{
+			name: "custom error handler",
+			opts: []Option{WithMaxFileSize(1), WithFileErrorResponseHandler(func(err error) http.HandlerFunc {
+				return func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(http.StatusTeapot)
+					w.Write([]byte("custom: " + err.Error())) // nolint: errcheck
+				}
+			})},
+			files:      []*MultipartFile{FileFromBytes("file", "a.txt", []byte("ab"))},
+			expectCode: http.StatusTeapot,
+			expectBody: "custom: " + ErrFileTooLarge.Error(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := MustNew(test.opts...).FileUploadMiddleware("file")(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+				t.Error("handler should not be called")
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, uploadRequest(t, NewMultipart(test.files...)))
+
+			assert.Equal(t, test.expectCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), test.expectBody)
+		})
+	}
+}
+
+func TestFileUploadMiddlewareNotMultipart(t *testing.T) {
+	var gotErr error
+
+	handler := MustNew(WithFileErrorResponseHandler(func(err error) http.HandlerFunc {
+		gotErr = err
+
+		return DefaultFileErrorResponseHandler(err)
+	})).FileUploadMiddleware("file")(http.NotFoundHandler())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("plain")))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.ErrorIs(t, gotErr, http.ErrNotMultipart)
+}